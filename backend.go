@@ -0,0 +1,97 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import "unsafe"
+
+// Backend executes the OpenCL calls of a runner.
+// The default backend calls the OpenCL library; FakeBackend runs kernels written in Go on the host,
+// so that code built on OpenCLRunner can be tested without an OpenCL driver.
+// Other backends, e.g. a remote device, can implement the interfaces below.
+type Backend interface {
+	// NewContext creates the context of a runner for the devices.
+	// callback, if not nil, receives the error notifications of the context.
+	NewContext(devices []*OpenCLDevice, callback ContextCallback) (BackendContext, error)
+}
+
+// The flag types of the backend interfaces, which can be named outside this package.
+type (
+	MemFlags        = C.cl_mem_flags
+	MapFlags        = C.cl_map_flags
+	QueueProperties = C.cl_command_queue_properties
+)
+
+// WithBackend runs the runner on the given backend instead of the OpenCL library.
+func WithBackend(backend Backend) RunnerOption {
+	return func(config *runnerConfig) {
+		config.backend = backend
+	}
+}
+
+// The backend interfaces mirror the OpenCL objects used by a runner.
+// Errors are returned as *Error named after the OpenCL API call, or *BuildError for failed builds.
+// Release is called exactly once, by the tracker of the runner.
+
+// BackendContext is the cl_context of a runner.
+type BackendContext interface {
+	NewQueue(device *OpenCLDevice, properties QueueProperties) (BackendQueue, error)
+	// NewBuffer creates a buffer of size bytes, initialized from or using host as the flags tell.
+	NewBuffer(flags MemFlags, size int, host unsafe.Pointer) (BackendBuffer, error)
+	// BuildProgram builds the sources for all devices of the context, using cache if it is not nil.
+	BuildProgram(sources []string, options string, cache *ProgramCache) (BackendProgram, error)
+	Release() ErrorCode
+}
+
+// BackendQueue is a cl_command_queue.
+type BackendQueue interface {
+	// The enqueue functions only return an event if withEvent is true.
+	EnqueueKernel(kernel BackendKernel, workDim int, offset, global, local []uint64,
+		waitList []BackendEvent, withEvent bool) (BackendEvent, error)
+	EnqueueRead(buffer BackendBuffer, blocking bool, offset int, size int, target unsafe.Pointer,
+		waitList []BackendEvent, withEvent bool) (BackendEvent, error)
+	EnqueueWrite(buffer BackendBuffer, blocking bool, offset int, size int, source unsafe.Pointer,
+		waitList []BackendEvent, withEvent bool) (BackendEvent, error)
+	MapBuffer(buffer BackendBuffer, flags MapFlags, offset int, size int) (unsafe.Pointer, error)
+	UnmapBuffer(buffer BackendBuffer, mapped unsafe.Pointer) error
+	Flush() error
+	Finish() error
+	Release() ErrorCode
+}
+
+// BackendProgram is a built cl_program.
+type BackendProgram interface {
+	BuildLog(device *OpenCLDevice) BuildLog
+	KernelNames() ([]string, error)
+	NewKernel(name string) (BackendKernel, error)
+	Release() ErrorCode
+}
+
+// BackendKernel is a cl_kernel.
+type BackendKernel interface {
+	NumArgs() (int, error)
+	// ArgInfo fails if the program was built without -cl-kernel-arg-info.
+	ArgInfo(index int) (KernelArg, error)
+	// SetArg sets an argument validated by the runner; KernelParam.Buffer returns the buffer of a BufferParam.
+	SetArg(index int, param KernelParam) error
+	// Clone returns a new kernel of the same program and name, without arguments.
+	Clone() (BackendKernel, error)
+	Release() ErrorCode
+}
+
+// BackendBuffer is a cl_mem buffer.
+type BackendBuffer interface {
+	Release() ErrorCode
+}
+
+// BackendEvent is the cl_event of an enqueued command.
+type BackendEvent interface {
+	Wait() error
+	// Status returns the execution status, or the negative error code the command failed with.
+	Status() (EventStatus, error)
+	// Notify calls done once with the final status of the command, possibly from a driver thread.
+	// If it fails, the status must be polled instead.
+	Notify(done func(status EventStatus)) error
+	Profile() (Profile, error)
+	Release() ErrorCode
+}
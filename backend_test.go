@@ -0,0 +1,170 @@
+package opencl_test
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+	"unsafe"
+
+	cl "github.com/nathanccxv/go-opencl"
+)
+
+// hostBackend is a Backend implemented outside the package. It runs the kernel
+// fill(__global int* out, int value) on the host.
+type hostBackend struct{}
+
+func (hostBackend) NewContext(devices []*cl.OpenCLDevice, callback cl.ContextCallback) (cl.BackendContext, error) {
+	return hostContext{}, nil
+}
+
+type hostContext struct{}
+
+func (hostContext) NewQueue(device *cl.OpenCLDevice, properties cl.QueueProperties) (cl.BackendQueue, error) {
+	return hostQueue{}, nil
+}
+
+func (hostContext) NewBuffer(flags cl.MemFlags, size int, host unsafe.Pointer) (cl.BackendBuffer, error) {
+	var buffer = &hostBuffer{data: make([]byte, size)}
+	if flags&cl.COPY_HOST_PTR != 0 {
+		copy(buffer.data, unsafe.Slice((*byte)(host), size))
+	}
+	return buffer, nil
+}
+
+func (hostContext) BuildProgram(sources []string, options string, cache *cl.ProgramCache) (cl.BackendProgram, error) {
+	return hostProgram{}, nil
+}
+
+func (hostContext) Release() cl.ErrorCode { return 0 }
+
+type hostProgram struct{}
+
+func (hostProgram) BuildLog(device *cl.OpenCLDevice) cl.BuildLog {
+	return cl.BuildLog{Device: device.Name}
+}
+func (hostProgram) KernelNames() ([]string, error) { return []string{"fill"}, nil }
+func (hostProgram) Release() cl.ErrorCode          { return 0 }
+
+func (hostProgram) NewKernel(name string) (cl.BackendKernel, error) {
+	if name != "fill" {
+		return nil, &cl.Error{Code: cl.ErrInvalidKernelName, Name: cl.ErrInvalidKernelName.Name(), Func: "clCreateKernel", Kernel: name}
+	}
+	return &hostKernel{}, nil
+}
+
+type hostKernel struct {
+	out   *hostBuffer
+	value int32
+}
+
+func (kernel *hostKernel) NumArgs() (int, error) { return 2, nil }
+
+func (kernel *hostKernel) ArgInfo(index int) (cl.KernelArg, error) {
+	return []cl.KernelArg{
+		{Name: "out", TypeName: "int*", AddressQualifier: cl.AddressGlobal},
+		{Name: "value", TypeName: "int", AddressQualifier: cl.AddressPrivate},
+	}[index], nil
+}
+
+func (kernel *hostKernel) SetArg(index int, param cl.KernelParam) error {
+	if index == 0 {
+		kernel.out = param.Buffer().(*hostBuffer)
+	} else {
+		kernel.value = *(*int32)(param.Pointer)
+	}
+	return nil
+}
+
+func (kernel *hostKernel) Clone() (cl.BackendKernel, error) { return &hostKernel{}, nil }
+func (kernel *hostKernel) Release() cl.ErrorCode            { return 0 }
+
+type hostBuffer struct {
+	data []byte
+}
+
+func (buffer *hostBuffer) Release() cl.ErrorCode { return 0 }
+
+type hostQueue struct{}
+
+func (hostQueue) EnqueueKernel(kernel cl.BackendKernel, workDim int, offset, global, local []uint64,
+	waitList []cl.BackendEvent, withEvent bool) (cl.BackendEvent, error) {
+	var fill = kernel.(*hostKernel)
+	for i := uint64(0); i < global[0]; i++ {
+		binary.NativeEndian.PutUint32(fill.out.data[4*i:], uint32(fill.value))
+	}
+	return newHostEvent(withEvent), nil
+}
+
+func (hostQueue) EnqueueRead(buffer cl.BackendBuffer, blocking bool, offset int, size int, target unsafe.Pointer,
+	waitList []cl.BackendEvent, withEvent bool) (cl.BackendEvent, error) {
+	copy(unsafe.Slice((*byte)(target), size), buffer.(*hostBuffer).data[offset:])
+	return newHostEvent(withEvent), nil
+}
+
+func (hostQueue) EnqueueWrite(buffer cl.BackendBuffer, blocking bool, offset int, size int, source unsafe.Pointer,
+	waitList []cl.BackendEvent, withEvent bool) (cl.BackendEvent, error) {
+	copy(buffer.(*hostBuffer).data[offset:], unsafe.Slice((*byte)(source), size))
+	return newHostEvent(withEvent), nil
+}
+
+func (hostQueue) MapBuffer(buffer cl.BackendBuffer, flags cl.MapFlags, offset int, size int) (unsafe.Pointer, error) {
+	return unsafe.Pointer(&buffer.(*hostBuffer).data[offset]), nil
+}
+
+func (hostQueue) UnmapBuffer(buffer cl.BackendBuffer, mapped unsafe.Pointer) error { return nil }
+func (hostQueue) Flush() error                                                     { return nil }
+func (hostQueue) Finish() error                                                    { return nil }
+func (hostQueue) Release() cl.ErrorCode                                            { return 0 }
+
+// hostEvent is the event of a command that completed when it was enqueued.
+type hostEvent struct{}
+
+func newHostEvent(withEvent bool) cl.BackendEvent {
+	if !withEvent {
+		return nil
+	}
+	return hostEvent{}
+}
+
+func (hostEvent) Wait() error                                   { return nil }
+func (hostEvent) Status() (cl.EventStatus, error)               { return cl.Complete, nil }
+func (hostEvent) Notify(done func(status cl.EventStatus)) error { done(cl.Complete); return nil }
+func (hostEvent) Release() cl.ErrorCode                         { return 0 }
+
+func (hostEvent) Profile() (cl.Profile, error) {
+	var code = cl.ErrProfilingInfoNotAvailable
+	return cl.Profile{}, &cl.Error{Code: code, Name: code.Name(), Func: "clGetEventProfilingInfo"}
+}
+
+func TestExternalBackend(t *testing.T) {
+	var device = &cl.OpenCLDevice{Name: "host"}
+	runner, err := device.InitRunner(cl.WithBackend(hostBackend{}))
+	if err != nil {
+		t.Fatal("InitRunner err:", err)
+	}
+	defer runner.Free()
+	if err = runner.CompileAllKernels([]string{"__kernel void fill(__global int* out, int value) {}"}, ""); err != nil {
+		t.Fatal("CompileAllKernels err:", err)
+	}
+
+	buffer, err := cl.CreateBuffer(runner, cl.READ_WRITE|cl.COPY_HOST_PTR, []int32{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal("CreateBuffer err:", err)
+	}
+	var value int32 = 7
+	if err = runner.RunKernel("fill", 1, nil, []uint64{3}, nil, []cl.KernelParam{cl.BufferParam(buffer), cl.Param(&value)}, true); err != nil {
+		t.Fatal("RunKernel err:", err)
+	}
+	var output = make([]int32, 4)
+	if err = cl.ReadBuffer(runner, 0, buffer, output); err != nil {
+		t.Fatal("ReadBuffer err:", err)
+	}
+	if want := []int32{7, 7, 7, 4}; !slices.Equal(output, want) {
+		t.Errorf("output %v, want %v", output, want)
+	}
+
+	var wrong int64
+	if err = runner.RunKernel("fill", 1, nil, []uint64{1}, nil, []cl.KernelParam{cl.BufferParam(buffer), cl.Param(&wrong)}, true); err == nil {
+		t.Error("RunKernel with an int64 value for an int argument succeeded")
+	}
+}
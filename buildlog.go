@@ -0,0 +1,104 @@
+package opencl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a single compiler message extracted from a build log.
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity string // "error", "warning", "note" or "remark"
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, d.Severity, d.Message)
+}
+
+// BuildLog is the compiler output of a program build for one device.
+type BuildLog struct {
+	Device      string
+	Log         string
+	Diagnostics []Diagnostic
+}
+
+// BuildError is returned by CompileKernels when clBuildProgram fails.
+// It unwraps to the underlying *Error, so errors.Is(err, ErrBuildProgramFailure) works.
+type BuildError struct {
+	Err     *Error
+	Options string
+	Logs    []BuildLog
+}
+
+func (e *BuildError) Error() string {
+	var msg = e.Err.Error()
+	for _, log := range e.Logs {
+		for _, d := range log.Diagnostics {
+			if d.Severity == "error" {
+				return fmt.Sprintf("%s on %s: %s", msg, log.Device, d)
+			}
+		}
+	}
+	if len(e.Logs) > 0 {
+		msg += " on " + e.Logs[0].Device
+	}
+	return msg
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostics returns the diagnostics of all devices.
+func (e *BuildError) Diagnostics() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, log := range e.Logs {
+		diagnostics = append(diagnostics, log.Diagnostics...)
+	}
+	return diagnostics
+}
+
+var (
+	// clang based compilers (Intel, AMD ROCm, NVIDIA, Apple, PoCL):
+	//   <kernel>:12:5: error: use of undeclared identifier 'x'
+	clangDiagnostic = regexp.MustCompile(`^(.*?):(\d+):(?:(\d+):)?\s*(fatal error|error|warning|note|remark):\s*(.*)$`)
+	// EDG based compilers (older AMD APP SDK):
+	//   "/tmp/OCL1234.cl", line 12: error: identifier "x" is undefined
+	edgDiagnostic = regexp.MustCompile(`^"(.*)", line (\d+):\s*(catastrophic error|error|warning|remark):\s*(.*)$`)
+)
+
+// parseBuildLog extracts the diagnostics from a vendor build log.
+// Lines that are not recognized (source excerpts, carets, summaries) are skipped.
+func parseBuildLog(log string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := clangDiagnostic.FindStringSubmatch(line); m != nil {
+			var d = Diagnostic{File: m[1], Severity: normalizeSeverity(m[4]), Message: m[5]}
+			d.Line, _ = strconv.Atoi(m[2])
+			d.Column, _ = strconv.Atoi(m[3])
+			diagnostics = append(diagnostics, d)
+		} else if m := edgDiagnostic.FindStringSubmatch(line); m != nil {
+			var d = Diagnostic{File: m[1], Severity: normalizeSeverity(m[3]), Message: m[4]}
+			d.Line, _ = strconv.Atoi(m[2])
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return diagnostics
+}
+
+func normalizeSeverity(severity string) string {
+	switch severity {
+	case "fatal error", "catastrophic error":
+		return "error"
+	}
+	return severity
+}
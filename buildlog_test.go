@@ -0,0 +1,39 @@
+package opencl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseBuildLog(t *testing.T) {
+	log := `<kernel>:3:5: error: use of undeclared identifier 'x'
+    x = 1;
+    ^
+<kernel>:7:1: warning: no previous prototype for function 'f'
+"/tmp/OCL1234.cl", line 12: error: identifier "y" is undefined
+C:\Temp\kernel.cl:4:9: fatal error: 'missing.h' file not found
+1 error generated.`
+
+	expected := []Diagnostic{
+		{File: "<kernel>", Line: 3, Column: 5, Severity: "error", Message: "use of undeclared identifier 'x'"},
+		{File: "<kernel>", Line: 7, Column: 1, Severity: "warning", Message: "no previous prototype for function 'f'"},
+		{File: "/tmp/OCL1234.cl", Line: 12, Severity: "error", Message: `identifier "y" is undefined`},
+		{File: `C:\Temp\kernel.cl`, Line: 4, Column: 9, Severity: "error", Message: "'missing.h' file not found"},
+	}
+	if result := parseBuildLog(log); !slices.Equal(result, expected) {
+		t.Fatalf("parseBuildLog:\n%v\nexpected:\n%v", result, expected)
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	err := &BuildError{
+		Err: &Error{Code: ErrBuildProgramFailure, Name: ErrBuildProgramFailure.Name(), Func: "clBuildProgram"},
+		Logs: []BuildLog{{Device: "gpu", Diagnostics: []Diagnostic{
+			{File: "<kernel>", Line: 3, Column: 5, Severity: "error", Message: "oops"},
+		}}},
+	}
+	expected := "clBuildProgram Err: CL_BUILD_PROGRAM_FAILURE (-11) on gpu: <kernel>:3:5: error: oops"
+	if err.Error() != expected {
+		t.Fatal("unexpected message:", err.Error())
+	}
+}
@@ -0,0 +1,56 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"runtime/cgo"
+	"unsafe"
+)
+
+// ContextCallback receives the error notifications of an OpenCL context (the pfn_notify callback of clCreateContext).
+// privateInfo is implementation specific binary data that may help debugging.
+// The callback may be invoked asynchronously from a driver thread.
+type ContextCallback func(errinfo string, privateInfo []byte)
+
+//export goContextNotify
+func goContextNotify(errinfo *C.char, private_info unsafe.Pointer, cb C.size_t, user_data unsafe.Pointer) {
+	var handle = cgo.Handle(*(*C.uintptr_t)(user_data))
+	var callback = handle.Value().(ContextCallback)
+
+	var privateInfo []byte
+	if private_info != nil && cb > 0 {
+		privateInfo = C.GoBytes(private_info, C.int(cb))
+	}
+	callback(C.GoString(errinfo), privateInfo)
+}
+
+// contextNotifyData holds a ContextCallback as user_data for clCreateContext.
+// The handle lives in C memory because the driver keeps the pointer.
+type contextNotifyData struct {
+	handle    cgo.Handle
+	user_data unsafe.Pointer
+}
+
+func newContextNotifyData(callback ContextCallback) *contextNotifyData {
+	var data = &contextNotifyData{handle: cgo.NewHandle(callback)}
+	data.user_data = C.malloc(C.sizeof_uintptr_t)
+	*(*C.uintptr_t)(data.user_data) = C.uintptr_t(data.handle)
+	return data
+}
+
+// free must only be called after the context has been released.
+func (data *contextNotifyData) free() {
+	C.free(data.user_data)
+	data.handle.Delete()
+}
+
+//export goEventNotify
+func goEventNotify(event C.cl_event, status C.cl_int, user_data unsafe.Pointer) {
+	// the callback of clSetEventCallback is called once, so its user_data is freed here
+	var handle = cgo.Handle(*(*C.uintptr_t)(user_data))
+	C.free(user_data)
+	var done = handle.Value().(func(EventStatus))
+	handle.Delete()
+	done(EventStatus(status))
+}
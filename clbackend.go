@@ -0,0 +1,487 @@
+package opencl
+
+// #include "cl.h"
+// extern void goContextNotify(char*, void*, size_t, void*);
+// extern void goEventNotify(cl_event, cl_int, void*);
+import "C"
+
+import (
+	"runtime/cgo"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// clBackend is the default Backend, which calls the OpenCL library.
+type clBackend struct{}
+
+type clContext struct {
+	context    C.cl_context
+	devices    []*OpenCLDevice
+	deviceIDs  []C.cl_device_id
+	notifyData *contextNotifyData
+}
+
+func (clBackend) NewContext(devices []*OpenCLDevice, callback ContextCallback) (BackendContext, error) {
+	var ctx = &clContext{devices: devices}
+	for _, device := range devices {
+		ctx.deviceIDs = append(ctx.deviceIDs, device.Device_id.id)
+	}
+
+	// clCreateContext
+	var context_properties = [3]C.cl_context_properties{
+		C.CL_CONTEXT_PLATFORM,
+		C.cl_context_properties(uintptr(unsafe.Pointer(devices[0].Platform_id.id))),
+		0,
+	}
+	var numDevices = C.cl_uint(len(ctx.deviceIDs))
+	var err C.cl_int
+	if callback != nil {
+		ctx.notifyData = newContextNotifyData(callback)
+		ctx.context = C.clCreateContext(&context_properties[0], numDevices, &ctx.deviceIDs[0],
+			(*[0]byte)(C.goContextNotify), ctx.notifyData.user_data, &err)
+	} else {
+		ctx.context = C.clCreateContext(&context_properties[0], numDevices, &ctx.deviceIDs[0], nil, nil, &err)
+	}
+	if err != C.CL_SUCCESS {
+		if ctx.notifyData != nil {
+			ctx.notifyData.free()
+		}
+		return nil, clError("clCreateContext", err)
+	}
+	return ctx, nil
+}
+
+func (ctx *clContext) Release() ErrorCode {
+	var err = C.clReleaseContext(ctx.context)
+	if ctx.notifyData != nil {
+		ctx.notifyData.free()
+	}
+	return ErrorCode(err)
+}
+
+func (ctx *clContext) NewQueue(device *OpenCLDevice, properties QueueProperties) (BackendQueue, error) {
+	var err C.cl_int
+	var commandQueue = C.clCreateCommandQueue(ctx.context, device.Device_id.id, properties, &err)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clCreateCommandQueue", err)
+	}
+	return &clQueue{queue: commandQueue}, nil
+}
+
+func (ctx *clContext) NewBuffer(flags MemFlags, size int, host unsafe.Pointer) (BackendBuffer, error) {
+	var err C.cl_int
+	var cl_mem = C.clCreateBuffer(ctx.context, flags, C.size_t(size), host, &err)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clCreateBuffer", err)
+	}
+	return &clBuffer{mem: cl_mem}, nil
+}
+
+func (ctx *clContext) BuildProgram(codeSourceList []string, options string, cache *ProgramCache) (BackendProgram, error) {
+	var err C.cl_int
+
+	cl_options := C.CString(options)
+	defer C.free(unsafe.Pointer(cl_options))
+
+	var program C.cl_program
+	var cacheKeys []string
+	if cache != nil {
+		cacheKeys = ctx.programCacheKeys(codeSourceList, options)
+		program = ctx.loadCachedProgram(cache, cacheKeys, cl_options)
+	}
+	if program != nil {
+		return &clProgram{program: program}, nil
+	}
+
+	var codes [](*C.char)
+	for _, codeSource := range codeSourceList {
+		code_src := C.CString(codeSource)
+		defer C.free(unsafe.Pointer(code_src))
+		codes = append(codes, code_src)
+	}
+
+	// clCreateProgramWithSource
+	program = C.clCreateProgramWithSource(ctx.context, C.cl_uint(len(codes)), &codes[0], nil, &err)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clCreateProgramWithSource", err)
+	}
+
+	// clBuildProgram
+	err = C.clBuildProgram(program, C.cl_uint(len(ctx.deviceIDs)), &ctx.deviceIDs[0], cl_options, nil, nil)
+	if err != C.CL_SUCCESS {
+		var buildErr = &BuildError{Err: clError("clBuildProgram", err), Options: options}
+		for _, device := range ctx.devices {
+			buildErr.Logs = append(buildErr.Logs, (&clProgram{program: program}).BuildLog(device))
+		}
+		C.clReleaseProgram(program)
+		return nil, buildErr
+	}
+
+	if cache != nil {
+		ctx.storeProgramBinaries(cache, cacheKeys, program)
+	}
+	return &clProgram{program: program}, nil
+}
+
+type clQueue struct {
+	queue C.cl_command_queue
+}
+
+func (queue *clQueue) Release() ErrorCode {
+	return ErrorCode(C.clReleaseCommandQueue(queue.queue))
+}
+
+// eventArgs returns the event_wait_list and event arguments of an enqueue call.
+// The returned function wraps the event written by the call.
+func eventArgs(waitList []BackendEvent, withEvent bool) (C.cl_uint, *C.cl_event, *C.cl_event, func() BackendEvent) {
+	var eventList []C.cl_event
+	for _, e := range waitList {
+		if e, ok := e.(*clEvent); ok {
+			eventList = append(eventList, e.event)
+		}
+	}
+	var numEvents C.cl_uint
+	var eventListPtr *C.cl_event
+	if len(eventList) > 0 {
+		numEvents, eventListPtr = C.cl_uint(len(eventList)), &eventList[0]
+	}
+	if !withEvent {
+		return numEvents, eventListPtr, nil, func() BackendEvent { return nil }
+	}
+	var evt_obj = new(C.cl_event)
+	return numEvents, eventListPtr, evt_obj, func() BackendEvent { return &clEvent{event: *evt_obj} }
+}
+
+func (queue *clQueue) EnqueueKernel(kernel BackendKernel, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64,
+	waitList []BackendEvent, withEvent bool) (BackendEvent, error) {
+	var clKernel, ok = kernel.(*clKernel)
+	if !ok {
+		return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidKernel)
+	}
+
+	var global_work_offset_ptr, global_work_size_ptr, local_work_size_ptr *C.size_t = nil, nil, nil
+
+	if len(global_work_offset) != 0 {
+		_global_work_offset := map_size_t(global_work_offset)
+		global_work_offset_ptr = &_global_work_offset[0]
+	}
+
+	if len(global_work_size) != 0 {
+		_global_work_size := map_size_t(global_work_size)
+		global_work_size_ptr = &_global_work_size[0]
+	}
+
+	if len(local_work_size) != 0 {
+		_local_work_size := map_size_t(local_work_size)
+		local_work_size_ptr = &_local_work_size[0]
+	}
+
+	var numEvents, eventListPtr, evt, event = eventArgs(waitList, withEvent)
+	var err = C.clEnqueueNDRangeKernel(queue.queue, clKernel.kernel, C.cl_uint(work_dim),
+		global_work_offset_ptr, global_work_size_ptr, local_work_size_ptr, numEvents, eventListPtr, evt)
+	if err != C.CL_SUCCESS {
+		return nil, kernelError("clEnqueueNDRangeKernel", err, clKernel.name)
+	}
+	return event(), nil
+}
+
+func (queue *clQueue) EnqueueRead(buffer BackendBuffer, blocking bool, offset int, size int, target unsafe.Pointer,
+	waitList []BackendEvent, withEvent bool) (BackendEvent, error) {
+	var numEvents, eventListPtr, evt, event = eventArgs(waitList, withEvent)
+	var err = C.clEnqueueReadBuffer(queue.queue, clMem(buffer), clBool(blocking), C.size_t(offset), C.size_t(size),
+		target, numEvents, eventListPtr, evt)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clEnqueueReadBuffer", err)
+	}
+	return event(), nil
+}
+
+func (queue *clQueue) EnqueueWrite(buffer BackendBuffer, blocking bool, offset int, size int, source unsafe.Pointer,
+	waitList []BackendEvent, withEvent bool) (BackendEvent, error) {
+	var numEvents, eventListPtr, evt, event = eventArgs(waitList, withEvent)
+	var err = C.clEnqueueWriteBuffer(queue.queue, clMem(buffer), clBool(blocking), C.size_t(offset), C.size_t(size),
+		source, numEvents, eventListPtr, evt)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clEnqueueWriteBuffer", err)
+	}
+	return event(), nil
+}
+
+func (queue *clQueue) MapBuffer(buffer BackendBuffer, flags MapFlags, offset int, size int) (unsafe.Pointer, error) {
+	var err C.cl_int
+	var ptr = C.clEnqueueMapBuffer(queue.queue, clMem(buffer), C.CL_TRUE, flags, C.size_t(offset), C.size_t(size),
+		0, nil, nil, &err)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clEnqueueMapBuffer", err)
+	}
+	return ptr, nil
+}
+
+func (queue *clQueue) UnmapBuffer(buffer BackendBuffer, mapped unsafe.Pointer) error {
+	var evt_obj C.cl_event
+	var err = C.clEnqueueUnmapMemObject(queue.queue, clMem(buffer), mapped, 0, nil, &evt_obj)
+	if err != C.CL_SUCCESS {
+		return clError("clEnqueueUnmapMemObject", err)
+	}
+	defer C.clReleaseEvent(evt_obj)
+	err = C.clWaitForEvents(1, &evt_obj)
+	if err != C.CL_SUCCESS {
+		return clError("clWaitForEvents", err)
+	}
+	return nil
+}
+
+func (queue *clQueue) Flush() error {
+	var err = C.clFlush(queue.queue)
+	if err != C.CL_SUCCESS {
+		return clError("clFlush", err)
+	}
+	return nil
+}
+
+func (queue *clQueue) Finish() error {
+	var err = C.clFinish(queue.queue)
+	if err != C.CL_SUCCESS {
+		return clError("clFinish", err)
+	}
+	return nil
+}
+
+type clBuffer struct {
+	mem C.cl_mem
+}
+
+func (buffer *clBuffer) Release() ErrorCode {
+	return ErrorCode(C.clReleaseMemObject(buffer.mem))
+}
+
+// clMem returns the memory object of a buffer, or nil for a buffer of another backend,
+// which the OpenCL call then rejects with CL_INVALID_MEM_OBJECT.
+func clMem(buffer BackendBuffer) C.cl_mem {
+	if buffer, ok := buffer.(*clBuffer); ok {
+		return buffer.mem
+	}
+	return nil
+}
+
+type clProgram struct {
+	program C.cl_program
+}
+
+func (program *clProgram) Release() ErrorCode {
+	return ErrorCode(C.clReleaseProgram(program.program))
+}
+
+// KernelNames returns the names of the kernels defined by the built program.
+func (program *clProgram) KernelNames() ([]string, error) {
+	var infoSize C.size_t
+	var err = C.clGetProgramInfo(program.program, C.CL_PROGRAM_KERNEL_NAMES, 0, nil, &infoSize)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clGetProgramInfo", err)
+	}
+	if infoSize <= 1 {
+		return nil, nil
+	}
+	var info = make([]byte, infoSize, infoSize)
+	err = C.clGetProgramInfo(program.program, C.CL_PROGRAM_KERNEL_NAMES, infoSize, unsafe.Pointer(&info[0]), nil)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clGetProgramInfo", err)
+	}
+	return splitKernelNames(string(info[:len(info)-1])), nil
+}
+
+// BuildLog fetches the build log of the program for the device.
+// The log is left empty if the driver cannot provide it.
+func (program *clProgram) BuildLog(device *OpenCLDevice) BuildLog {
+	var log = BuildLog{Device: device.Name}
+
+	var logSize C.size_t
+	var err = C.clGetProgramBuildInfo(program.program, device.Device_id.id, C.CL_PROGRAM_BUILD_LOG, 0, nil, &logSize)
+	if err != C.CL_SUCCESS || logSize <= 1 {
+		return log
+	}
+
+	var log_buf = make([]byte, logSize, logSize)
+	err = C.clGetProgramBuildInfo(program.program, device.Device_id.id, C.CL_PROGRAM_BUILD_LOG, logSize,
+		unsafe.Pointer(&log_buf[0]), nil)
+	if err != C.CL_SUCCESS {
+		return log
+	}
+
+	log.Log = strings.TrimRight(string(log_buf[:len(log_buf)-1]), "\n")
+	log.Diagnostics = parseBuildLog(log.Log)
+	return log
+}
+
+func (program *clProgram) NewKernel(kernelName string) (BackendKernel, error) {
+	return createKernel(program.program, kernelName)
+}
+
+type clKernel struct {
+	kernel  C.cl_kernel
+	program C.cl_program
+	name    string
+}
+
+func createKernel(program C.cl_program, kernelName string) (*clKernel, error) {
+	var kernel_name = C.CString(kernelName)
+	defer C.free(unsafe.Pointer(kernel_name))
+
+	var err C.cl_int
+	var kernel = C.clCreateKernel(program, kernel_name, &err)
+	if err != C.CL_SUCCESS {
+		return nil, kernelError("clCreateKernel", err, kernelName)
+	}
+	return &clKernel{kernel: kernel, program: program, name: kernelName}, nil
+}
+
+func (kernel *clKernel) Release() ErrorCode {
+	return ErrorCode(C.clReleaseKernel(kernel.kernel))
+}
+
+// clone creates the kernel anew from its program.
+// clCloneKernel needs OpenCL 2.1 and copies the argument values, which are set before each enqueue anyway.
+func (kernel *clKernel) Clone() (BackendKernel, error) {
+	return createKernel(kernel.program, kernel.name)
+}
+
+func (kernel *clKernel) NumArgs() (int, error) {
+	var numArgs C.cl_uint
+	var err = C.clGetKernelInfo(kernel.kernel, C.CL_KERNEL_NUM_ARGS, C.sizeof_cl_uint, unsafe.Pointer(&numArgs), nil)
+	if err != C.CL_SUCCESS {
+		return 0, kernelError("clGetKernelInfo", err, kernel.name)
+	}
+	return int(numArgs), nil
+}
+
+func (kernel *clKernel) SetArg(index int, param KernelParam) error {
+	var size, value = C.size_t(param.Size), param.Pointer
+	if param.buffer != nil {
+		var mem = clMem(param.buffer.buffer)
+		size, value = C.size_t(unsafe.Sizeof(mem)), unsafe.Pointer(&mem)
+	}
+	var err = C.clSetKernelArg(kernel.kernel, C.cl_uint(index), size, value)
+	if err != C.CL_SUCCESS {
+		return kernelError("clSetKernelArg", err, kernel.name)
+	}
+	return nil
+}
+
+func (kernel *clKernel) ArgInfo(index int) (KernelArg, error) {
+	var arg KernelArg
+	var cl_index = C.cl_uint(index)
+
+	var err = C.clGetKernelArgInfo(kernel.kernel, cl_index, C.CL_KERNEL_ARG_ADDRESS_QUALIFIER,
+		C.sizeof_cl_kernel_arg_address_qualifier, unsafe.Pointer(&arg.AddressQualifier), nil)
+	if err != C.CL_SUCCESS {
+		return arg, clError("clGetKernelArgInfo", err)
+	}
+
+	err = C.clGetKernelArgInfo(kernel.kernel, cl_index, C.CL_KERNEL_ARG_ACCESS_QUALIFIER,
+		C.sizeof_cl_kernel_arg_access_qualifier, unsafe.Pointer(&arg.AccessQualifier), nil)
+	if err != C.CL_SUCCESS {
+		return arg, clError("clGetKernelArgInfo", err)
+	}
+
+	for _, param := range []struct {
+		name   C.cl_kernel_arg_info
+		target *string
+	}{
+		{C.CL_KERNEL_ARG_TYPE_NAME, &arg.TypeName},
+		{C.CL_KERNEL_ARG_NAME, &arg.Name},
+	} {
+		var infoSize C.size_t
+		err = C.clGetKernelArgInfo(kernel.kernel, cl_index, param.name, 0, nil, &infoSize)
+		if err != C.CL_SUCCESS {
+			return arg, clError("clGetKernelArgInfo", err)
+		}
+		if infoSize <= 1 {
+			continue
+		}
+		var info = make([]byte, infoSize, infoSize)
+		err = C.clGetKernelArgInfo(kernel.kernel, cl_index, param.name, infoSize, unsafe.Pointer(&info[0]), nil)
+		if err != C.CL_SUCCESS {
+			return arg, clError("clGetKernelArgInfo", err)
+		}
+		*param.target = string(info[:len(info)-1])
+	}
+
+	return arg, nil
+}
+
+type clEvent struct {
+	event C.cl_event
+}
+
+func (e *clEvent) Release() ErrorCode {
+	return ErrorCode(C.clReleaseEvent(e.event))
+}
+
+func (e *clEvent) Wait() error {
+	var err = C.clWaitForEvents(1, &e.event)
+	if err != C.CL_SUCCESS {
+		return clError("clWaitForEvents", err)
+	}
+	return nil
+}
+
+func (e *clEvent) Status() (EventStatus, error) {
+	var status C.cl_int
+	var err = C.clGetEventInfo(e.event, C.CL_EVENT_COMMAND_EXECUTION_STATUS, C.sizeof_cl_int, unsafe.Pointer(&status), nil)
+	if err != C.CL_SUCCESS {
+		return 0, clError("clGetEventInfo", err)
+	}
+	return EventStatus(status), nil
+}
+
+func (e *clEvent) Notify(done func(status EventStatus)) error {
+	var handle = cgo.NewHandle(done)
+	var user_data = C.malloc(C.sizeof_uintptr_t)
+	*(*C.uintptr_t)(user_data) = C.uintptr_t(handle)
+	var err = C.clSetEventCallback(e.event, C.CL_COMPLETE, (*[0]byte)(C.goEventNotify), user_data)
+	if err != C.CL_SUCCESS {
+		C.free(user_data)
+		handle.Delete()
+		return clError("clSetEventCallback", err)
+	}
+	return nil
+}
+
+func (e *clEvent) Profile() (Profile, error) {
+	var profile Profile
+	for _, param := range []struct {
+		name   C.cl_profiling_info
+		target *time.Duration
+	}{
+		{C.CL_PROFILING_COMMAND_QUEUED, &profile.Queued},
+		{C.CL_PROFILING_COMMAND_SUBMIT, &profile.Submitted},
+		{C.CL_PROFILING_COMMAND_START, &profile.Started},
+		{C.CL_PROFILING_COMMAND_END, &profile.Ended},
+	} {
+		var value C.cl_ulong
+		var err = C.clGetEventProfilingInfo(e.event, param.name, C.sizeof_cl_ulong, unsafe.Pointer(&value), nil)
+		if err != C.CL_SUCCESS {
+			return profile, clError("clGetEventProfilingInfo", err)
+		}
+		*param.target = time.Duration(value)
+	}
+	return profile, nil
+}
+
+func clBool(b bool) C.cl_bool {
+	if b {
+		return C.CL_TRUE
+	}
+	return C.CL_FALSE
+}
+
+// map_size_t converts a slice of uint64 to a slice of C.size_t.
+func map_size_t[E uint64](slice []E) []C.size_t {
+	size_t_slice := make([]C.size_t, len(slice), len(slice))
+	for i, v := range slice {
+		size_t_slice[i] = C.size_t(v)
+	}
+	return size_t_slice
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cl "github.com/nathanccxv/go-opencl"
+)
+
+const benchmarkSource = `
+__kernel void bench_copy(__global const float4* src, __global float4* dst) {
+	size_t i = get_global_id(0);
+	dst[i] = src[i];
+}
+
+__kernel void bench_empty(__global int* out) {
+}
+`
+
+// benchmarkFMASource is built once per precision with -DREAL=float or -DREAL=double and a matching -DKERNEL name.
+// Every work item runs 4 independent chains of REAL4 FMAs: FMA_ITERATIONS*4*4*2 flops.
+const benchmarkFMASource = `
+#ifdef USE_FP64
+#pragma OPENCL EXTENSION cl_khr_fp64 : enable
+#endif
+#define CONCAT(a, b) a##b
+#define VEC4(t) CONCAT(t, 4)
+
+__kernel void KERNEL(__global REAL* out, REAL seed) {
+	VEC4(REAL) a = (VEC4(REAL))((REAL)0.999, (REAL)0.998, (REAL)0.997, (REAL)0.996);
+	VEC4(REAL) b = (VEC4(REAL))(seed);
+	VEC4(REAL) x = b + (REAL)get_global_id(0), y = x + 1, z = x + 2, w = x + 3;
+	for (int i = 0; i < FMA_ITERATIONS; i++) {
+		x = fma(x, a, b);
+		y = fma(y, a, b);
+		z = fma(z, a, b);
+		w = fma(w, a, b);
+	}
+	VEC4(REAL) r = x + y + z + w;
+	out[get_global_id(0)] = r.x + r.y + r.z + r.w;
+}
+`
+
+const (
+	fmaIterations    = 512
+	fmaFlopsPerItem  = fmaIterations * 4 * 4 * 2
+	launchIterations = 100
+	computeRepeats   = 5
+)
+
+// benchmarkResult holds the measurements of one device. Bandwidths are in GB/s.
+type benchmarkResult struct {
+	Platform      int                `json:"platform"`
+	Device        int                `json:"device"`
+	Name          string             `json:"name"`
+	Transfers     []transferResult   `json:"transfers"`
+	DeviceCopy    float64            `json:"device_copy_gbps"`
+	LaunchLatency float64            `json:"launch_latency_us"`
+	GFLOPS        map[string]float64 `json:"gflops"`
+	Error         string             `json:"error,omitempty"`
+}
+
+type transferResult struct {
+	Size               int     `json:"size"`
+	HostToDevice       float64 `json:"host_to_device_gbps"`
+	DeviceToHost       float64 `json:"device_to_host_gbps"`
+	PinnedHostToDevice float64 `json:"pinned_host_to_device_gbps"`
+	PinnedDeviceToHost float64 `json:"pinned_device_to_host_gbps"`
+}
+
+// benchmarkCommand measures transfer bandwidth, launch latency and compute throughput of the selected devices.
+// It exits with 1 if a benchmark fails on any device.
+func benchmarkCommand(args []string, stdout, stderr io.Writer) int {
+	var flags = flag.NewFlagSet("cl-info benchmark", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var platformSel = flags.String("platform", "", "only benchmark the platform with this `index or name`")
+	var deviceSel = flags.String("device", "", "only benchmark the device with this `index, name or platform:device` index")
+	var sizeList = flags.String("sizes", "64K,1M,16M,64M", "comma separated transfer `sizes` in bytes, with an optional K, M or G suffix")
+	var iterations = flags.Int("iterations", 0, "transfers per size, 0 to move about 256M per size")
+	var jsonOutput = flags.Bool("json", false, "print JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	sizes, err := parseSizes(*sizeList)
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info benchmark:", err)
+		return 2
+	}
+
+	info, err := loadInfo()
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info benchmark:", err)
+		return 1
+	}
+	selections, err := selectInfo(info, *platformSel, *deviceSel)
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info benchmark:", err)
+		return 2
+	}
+
+	var results []benchmarkResult
+	var status = 0
+	for _, s := range selections {
+		for _, d := range s.devices {
+			var result = benchmarkResult{Platform: s.index, Device: d.index, Name: d.device.Name}
+			if err := benchmarkDevice(d.device, sizes, *iterations, &result); err != nil {
+				result.Error = err.Error()
+				status = 1
+			}
+			results = append(results, result)
+		}
+	}
+
+	if *jsonOutput {
+		var encoder = json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+	} else {
+		printBenchmarkResults(stdout, results)
+	}
+	return status
+}
+
+// parseSizes parses a list like "64K,1M" into bytes.
+func parseSizes(list string) ([]int, error) {
+	var sizes []int
+	for _, s := range strings.Split(list, ",") {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		var unit = 1
+		switch {
+		case strings.HasSuffix(s, "K"):
+			unit = 1 << 10
+		case strings.HasSuffix(s, "M"):
+			unit = 1 << 20
+		case strings.HasSuffix(s, "G"):
+			unit = 1 << 30
+		}
+		if unit != 1 {
+			s = s[:len(s)-1]
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid size %q", s)
+		}
+		sizes = append(sizes, n*unit)
+	}
+	return sizes, nil
+}
+
+func benchmarkDevice(device *cl.OpenCLDevice, sizes []int, iterations int, result *benchmarkResult) error {
+	runner, err := device.InitRunner()
+	if err != nil {
+		return err
+	}
+	defer runner.Free()
+
+	if err = runner.CompileKernels([]string{benchmarkSource}, []string{"bench_copy", "bench_empty"}, ""); err != nil {
+		return err
+	}
+
+	var largest int
+	for _, size := range sizes {
+		if uint64(size) > device.Max_mem_alloc_size {
+			continue
+		}
+		transfer, err := benchmarkTransfers(runner, size, iterations)
+		if err != nil {
+			return fmt.Errorf("transfer of %s: %w", formatBytes(uint64(size)), err)
+		}
+		result.Transfers = append(result.Transfers, transfer)
+		largest = max(largest, size)
+	}
+
+	if largest >= 16 {
+		if result.DeviceCopy, err = benchmarkDeviceCopy(runner, largest); err != nil {
+			return fmt.Errorf("device copy: %w", err)
+		}
+	}
+	if result.LaunchLatency, err = benchmarkLaunchLatency(runner); err != nil {
+		return fmt.Errorf("launch latency: %w", err)
+	}
+
+	result.GFLOPS = make(map[string]float64)
+	var precisions = []string{"float"}
+	if device.Supports("cl_khr_fp64") {
+		precisions = append(precisions, "double")
+	}
+	for _, precision := range precisions {
+		gflops, err := benchmarkFMA(runner, device, precision)
+		if err != nil {
+			return fmt.Errorf("%s FMA: %w", precision, err)
+		}
+		result.GFLOPS[precision] = gflops
+	}
+	return nil
+}
+
+// transferIterations moves about 256M per size unless the iterations are given.
+func transferIterations(size, iterations int) int {
+	if iterations > 0 {
+		return iterations
+	}
+	return min(max((256<<20)/size, 3), 1000)
+}
+
+// gbps returns the bandwidth in GB/s of moving bytes in the elapsed time.
+func gbps(bytes int, elapsed time.Duration) float64 {
+	return float64(bytes) / elapsed.Seconds() / 1e9
+}
+
+func benchmarkTransfers(runner *cl.OpenCLRunner, size, iterations int) (transferResult, error) {
+	var result = transferResult{Size: size}
+	iterations = transferIterations(size, iterations)
+
+	buffer, err := runner.CreateEmptyBuffer(cl.READ_WRITE, size)
+	if err != nil {
+		return result, err
+	}
+	defer buffer.Release()
+	var pageable = make([]byte, size)
+
+	var measure = func(transfer func() error) (float64, error) {
+		if err := transfer(); err != nil { // warm up
+			return 0, err
+		}
+		var start = time.Now()
+		for i := 0; i < iterations; i++ {
+			if err := transfer(); err != nil {
+				return 0, err
+			}
+		}
+		return gbps(size*iterations, time.Since(start)), nil
+	}
+
+	if result.HostToDevice, err = measure(func() error { return cl.WriteBuffer(runner, 0, buffer, pageable, true) }); err != nil {
+		return result, err
+	}
+	if result.DeviceToHost, err = measure(func() error { return cl.ReadBuffer(runner, 0, buffer, pageable) }); err != nil {
+		return result, err
+	}
+
+	// Pinned host memory is obtained by mapping a buffer allocated by the driver in host memory.
+	hostBuffer, err := runner.CreateEmptyBuffer(cl.READ_WRITE|cl.ALLOC_HOST_PTR, size)
+	if err != nil {
+		return result, err
+	}
+	defer hostBuffer.Release()
+	pinned, err := cl.MapBuffer[byte](runner, hostBuffer, cl.MAP_READ|cl.MAP_WRITE, 0, size)
+	if err != nil {
+		return result, err
+	}
+	// unmapped before hostBuffer is released
+	defer cl.UnmapBuffer(runner, hostBuffer, pinned)
+
+	if result.PinnedHostToDevice, err = measure(func() error { return cl.WriteBuffer(runner, 0, buffer, pinned, true) }); err != nil {
+		return result, err
+	}
+	if result.PinnedDeviceToHost, err = measure(func() error { return cl.ReadBuffer(runner, 0, buffer, pinned) }); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// benchmarkDeviceCopy copies size bytes between two device buffers with a kernel; each byte is read and written.
+func benchmarkDeviceCopy(runner *cl.OpenCLRunner, size int) (float64, error) {
+	src, err := runner.CreateEmptyBuffer(cl.READ_ONLY, size)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Release()
+	dst, err := runner.CreateEmptyBuffer(cl.WRITE_ONLY, size)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Release()
+	var args = []cl.KernelParam{cl.BufferParam(src), cl.BufferParam(dst)}
+	var globalSize = []uint64{uint64(size / 16)}
+	var iterations = transferIterations(size, 0)
+
+	if err = runner.RunKernel("bench_copy", 1, nil, globalSize, nil, args, true); err != nil {
+		return 0, err
+	}
+	var start = time.Now()
+	for i := 0; i < iterations; i++ {
+		if err = runner.RunKernel("bench_copy", 1, nil, globalSize, nil, args, false); err != nil {
+			return 0, err
+		}
+	}
+	if err = runner.Finish(); err != nil {
+		return 0, err
+	}
+	return gbps(2*(size/16*16)*iterations, time.Since(start)), nil
+}
+
+// benchmarkLaunchLatency returns the average time in µs to run an empty kernel and wait for it.
+func benchmarkLaunchLatency(runner *cl.OpenCLRunner) (float64, error) {
+	out, err := runner.CreateEmptyBuffer(cl.WRITE_ONLY, 4)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Release()
+	var args = []cl.KernelParam{cl.BufferParam(out)}
+	if err = runner.RunKernel("bench_empty", 1, nil, []uint64{1}, nil, args, true); err != nil {
+		return 0, err
+	}
+	var start = time.Now()
+	for i := 0; i < launchIterations; i++ {
+		if err = runner.RunKernel("bench_empty", 1, nil, []uint64{1}, nil, args, true); err != nil {
+			return 0, err
+		}
+	}
+	return float64(time.Since(start).Microseconds()) / launchIterations, nil
+}
+
+// benchmarkFMA returns the throughput in GFLOPS of the FMA kernel in the given precision.
+func benchmarkFMA(runner *cl.OpenCLRunner, device *cl.OpenCLDevice, precision string) (float64, error) {
+	var kernelName = "bench_fma_" + precision
+	var options = fmt.Sprintf("-DREAL=%s -DKERNEL=%s -DFMA_ITERATIONS=%d", precision, kernelName, fmaIterations)
+	if precision == "double" {
+		options += " -DUSE_FP64"
+	}
+	if err := runner.CompileKernels([]string{benchmarkFMASource}, []string{kernelName}, options); err != nil {
+		return 0, err
+	}
+
+	var workItems = int(device.Max_compute_units) * max(device.Max_work_group_size, 1) * 16
+	var elementSize = 4
+	if precision == "double" {
+		elementSize = 8
+	}
+	out, err := runner.CreateEmptyBuffer(cl.WRITE_ONLY, workItems*elementSize)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Release()
+	var seed32, seed64 = float32(0.5), float64(0.5)
+	var seed = cl.Param(&seed32)
+	if precision == "double" {
+		seed = cl.Param(&seed64)
+	}
+	var args = []cl.KernelParam{cl.BufferParam(out), seed}
+	var globalSize = []uint64{uint64(workItems)}
+
+	if err = runner.RunKernel(kernelName, 1, nil, globalSize, nil, args, true); err != nil {
+		return 0, err
+	}
+	var start = time.Now()
+	for i := 0; i < computeRepeats; i++ {
+		if err = runner.RunKernel(kernelName, 1, nil, globalSize, nil, args, false); err != nil {
+			return 0, err
+		}
+	}
+	if err = runner.Finish(); err != nil {
+		return 0, err
+	}
+	var flops = float64(workItems) * fmaFlopsPerItem * computeRepeats
+	return flops / time.Since(start).Seconds() / 1e9, nil
+}
+
+func printBenchmarkResults(w io.Writer, results []benchmarkResult) {
+	var tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	for _, result := range results {
+		fmt.Fprintf(w, "Device %d:%d: %s\n", result.Platform, result.Device, result.Name)
+		if len(result.Transfers) > 0 {
+			fmt.Fprintln(tw, "  Size\tH2D GB/s\tD2H GB/s\tPinned H2D GB/s\tPinned D2H GB/s\t")
+			for _, t := range result.Transfers {
+				fmt.Fprintf(tw, "  %s\t%.2f\t%.2f\t%.2f\t%.2f\t\n", formatBytes(uint64(t.Size)),
+					t.HostToDevice, t.DeviceToHost, t.PinnedHostToDevice, t.PinnedDeviceToHost)
+			}
+			tw.Flush()
+		}
+		if result.DeviceCopy > 0 {
+			fmt.Fprintf(w, "  Device copy:     %.2f GB/s\n", result.DeviceCopy)
+		}
+		if result.LaunchLatency > 0 {
+			fmt.Fprintf(w, "  Launch latency:  %.1f µs\n", result.LaunchLatency)
+		}
+		for _, precision := range []string{"float", "double"} {
+			if gflops, ok := result.GFLOPS[precision]; ok {
+				fmt.Fprintf(w, "  FMA %-7s       %.1f GFLOPS\n", precision+":", gflops)
+			}
+		}
+		if result.Error != "" {
+			fmt.Fprintf(w, "  Error: %s\n", result.Error)
+		}
+		fmt.Fprintln(w)
+	}
+}
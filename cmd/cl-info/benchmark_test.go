@@ -0,0 +1,31 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSizes(t *testing.T) {
+	sizes, err := parseSizes("64k, 1M,4096,2G")
+	if want := []int{64 << 10, 1 << 20, 4096, 2 << 30}; err != nil || !slices.Equal(sizes, want) {
+		t.Errorf("parseSizes = %v, %v, want %v", sizes, err, want)
+	}
+	for _, list := range []string{"", "1X", "-1M", "1M,"} {
+		if _, err := parseSizes(list); err == nil {
+			t.Errorf("parseSizes(%q) accepted an invalid size", list)
+		}
+	}
+}
+
+func TestTransferIterations(t *testing.T) {
+	for _, test := range []struct{ size, iterations, want int }{
+		{64 << 10, 0, 1000},
+		{16 << 20, 0, 16},
+		{1 << 30, 0, 3},
+		{1 << 30, 7, 7},
+	} {
+		if got := transferIterations(test.size, test.iterations); got != test.want {
+			t.Errorf("transferIterations(%d, %d) = %d, want %d", test.size, test.iterations, got, test.want)
+		}
+	}
+}
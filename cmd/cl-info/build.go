@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	cl "github.com/nathanccxv/go-opencl"
+)
+
+// buildResult is the outcome of the build command, also printed as JSON.
+type buildResult struct {
+	Device  string        `json:"device"`
+	Options string        `json:"options"`
+	Error   string        `json:"error,omitempty"`
+	Logs    []cl.BuildLog `json:"logs,omitempty"`
+	Kernels []buildKernel `json:"kernels,omitempty"`
+}
+
+type buildKernel struct {
+	Name      string   `json:"name"`
+	Signature string   `json:"signature"`
+	Args      []string `json:"args,omitempty"`
+}
+
+// buildCommand compiles kernel sources on a device like an application would with CompileKernels or CompileAllKernels.
+// It exits with 1 if the build fails.
+func buildCommand(args []string, stdout, stderr io.Writer) int {
+	var flags = flag.NewFlagSet("cl-info build", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cl-info build [flags] file.cl...")
+		flags.PrintDefaults()
+	}
+	var options = flags.String("options", "", "build `options` passed to clBuildProgram, e.g. \"-cl-std=CL2.0 -DN=4\"")
+	var deviceSel = flags.String("device", "", "build on the device with this `index, name or platform:device` index instead of the best one")
+	var kernelList = flags.String("kernels", "", "comma separated `names` of the kernels to create, all kernels if empty")
+	var argInfo = flags.Bool("arg-info", false, "build with -cl-kernel-arg-info to print the names and types of kernel arguments")
+	var jsonOutput = flags.Bool("json", false, "print JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var sources []string
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, "cl-info build:", err)
+			return 2
+		}
+		sources = append(sources, string(source))
+	}
+	var kernelNames []string
+	if *kernelList != "" {
+		for _, name := range strings.Split(*kernelList, ",") {
+			kernelNames = append(kernelNames, strings.TrimSpace(name))
+		}
+	}
+
+	device, err := buildDevice(*deviceSel)
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info build:", err)
+		return 2
+	}
+	runner, err := device.InitRunner()
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info build:", err)
+		return 1
+	}
+	defer runner.Free()
+
+	var buildOptions = effectiveOptions(*options, *argInfo)
+	var result = buildResult{Device: device.Name, Options: buildOptions}
+	if len(kernelNames) == 0 {
+		err = runner.CompileAllKernels(sources, buildOptions)
+	} else {
+		err = runner.CompileKernels(sources, kernelNames, buildOptions)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		var buildErr *cl.BuildError
+		if errors.As(err, &buildErr) {
+			result.Logs = buildErr.Logs
+		}
+	} else {
+		var program = runner.Programs[len(runner.Programs)-1]
+		result.Logs = program.Logs
+		for _, kernel := range program.Kernels {
+			result.Kernels = append(result.Kernels, newBuildKernel(kernel))
+		}
+		sort.Slice(result.Kernels, func(i, j int) bool { return result.Kernels[i].Name < result.Kernels[j].Name })
+	}
+
+	if *jsonOutput {
+		var encoder = json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(result)
+	} else {
+		printBuildResult(stdout, result)
+	}
+	if result.Error != "" {
+		return 1
+	}
+	return 0
+}
+
+// effectiveOptions returns the options passed to clBuildProgram.
+// Argument names and types are only available to clGetKernelArgInfo with -cl-kernel-arg-info.
+func effectiveOptions(options string, argInfo bool) string {
+	if argInfo {
+		options += " -cl-kernel-arg-info"
+	}
+	return strings.TrimSpace(options)
+}
+
+// buildDevice returns the device named by the selector, or the best device if it is empty.
+func buildDevice(deviceSel string) (*cl.OpenCLDevice, error) {
+	info, err := loadInfo()
+	if err != nil {
+		return nil, err
+	}
+	return selectBuildDevice(info, deviceSel)
+}
+
+func selectBuildDevice(info *cl.OpenCLInfo, deviceSel string) (*cl.OpenCLDevice, error) {
+	if deviceSel == "" {
+		return info.SelectDevice(cl.DeviceFilter{})
+	}
+	selections, err := selectInfo(info, "", deviceSel)
+	if err != nil {
+		return nil, err
+	}
+	if len(selections) == 0 || len(selections[0].devices) == 0 {
+		return nil, fmt.Errorf("no device matches -device %q", deviceSel)
+	}
+	return selections[0].devices[0].device, nil
+}
+
+func newBuildKernel(kernel *cl.Kernel) buildKernel {
+	var k = buildKernel{Name: kernel.Name}
+	if kernel.Args == nil {
+		k.Signature = fmt.Sprintf("__kernel void %s(/* %d arguments */)", kernel.Name, kernel.NumArgs)
+		return k
+	}
+	for _, arg := range kernel.Args {
+		k.Args = append(k.Args, arg.String())
+	}
+	k.Signature = fmt.Sprintf("__kernel void %s(%s)", kernel.Name, strings.Join(k.Args, ", "))
+	return k
+}
+
+func printBuildResult(w io.Writer, result buildResult) {
+	for _, log := range result.Logs {
+		if len(log.Diagnostics) == 0 {
+			if log.Log != "" {
+				fmt.Fprintf(w, "%s:\n%s\n", log.Device, log.Log)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", log.Device)
+		for _, d := range log.Diagnostics {
+			fmt.Fprintf(w, "  %s\n", d)
+		}
+	}
+	if result.Error != "" {
+		fmt.Fprintf(w, "build failed on %s with options %q: %s\n", result.Device, result.Options, result.Error)
+		return
+	}
+	fmt.Fprintf(w, "build succeeded on %s with options %q, %d kernels:\n", result.Device, result.Options, len(result.Kernels))
+	for _, kernel := range result.Kernels {
+		fmt.Fprintf(w, "  %s\n", kernel.Signature)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	cl "github.com/nathanccxv/go-opencl"
+)
+
+func TestPrintBuildResult(t *testing.T) {
+	var result = buildResult{Device: "gpu0", Options: "-DN=4", Error: "clBuildProgram Err: CL_BUILD_PROGRAM_FAILURE (-11)", Logs: []cl.BuildLog{
+		{Device: "gpu0", Diagnostics: []cl.Diagnostic{{File: "<source>", Line: 3, Column: 5, Severity: "error", Message: "use of undeclared identifier 'x'"}}},
+	}}
+	var buf bytes.Buffer
+	printBuildResult(&buf, result)
+	var want = "gpu0:\n  <source>:3:5: error: use of undeclared identifier 'x'\n" +
+		"build failed on gpu0 with options \"-DN=4\": clBuildProgram Err: CL_BUILD_PROGRAM_FAILURE (-11)\n"
+	if buf.String() != want {
+		t.Errorf("printBuildResult = %q, want %q", buf.String(), want)
+	}
+
+	var kernel = newBuildKernel(&cl.Kernel{Name: "square", NumArgs: 2, Args: []cl.KernelArg{
+		{Name: "in", TypeName: "int*", AddressQualifier: cl.AddressGlobal, AccessQualifier: cl.AccessNone},
+		{Name: "n", TypeName: "uint", AddressQualifier: cl.AddressPrivate, AccessQualifier: cl.AccessNone},
+	}})
+	if want := "__kernel void square(__global int* in, uint n)"; kernel.Signature != want {
+		t.Errorf("Signature = %q, want %q", kernel.Signature, want)
+	}
+}
+
+func TestEffectiveOptions(t *testing.T) {
+	if options := effectiveOptions("-DN=4", false); options != "-DN=4" {
+		t.Errorf("options without -arg-info = %q", options)
+	}
+	if options := effectiveOptions("", true); options != "-cl-kernel-arg-info" {
+		t.Errorf("options with -arg-info = %q", options)
+	}
+}
+
+func TestSelectBuildDevice(t *testing.T) {
+	var info = testInfo()
+	info.Platforms = append(info.Platforms, &cl.OpenCLPlatform{Name: "Empty"})
+	if device, err := selectBuildDevice(info, "1:0"); err != nil || device.Name != "cpu-haswell" {
+		t.Errorf("selectBuildDevice(1:0) = %v, %v", device, err)
+	}
+	if _, err := selectBuildDevice(info, "2:"); err == nil {
+		t.Error("selecting a platform without devices succeeded")
+	}
+}
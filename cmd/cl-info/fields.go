@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	cl "github.com/nathanccxv/go-opencl"
+)
+
+// field is a named value of a platform or device, in struct order.
+type field struct {
+	Name  string
+	Value any // a number, bool, string, []int or []string
+}
+
+// fields is an ordered list of fields that encodes as a JSON object.
+type fields []field
+
+func (list fields) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range list {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// lookup returns the field with the given name, ignoring case.
+func (list fields) lookup(name string) (field, bool) {
+	for _, f := range list {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+var (
+	stringerType   = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	deviceIDType   = reflect.TypeOf(cl.DeviceID{})
+	platformIDType = reflect.TypeOf(cl.PlatformID{})
+	extensionsType = reflect.TypeOf(cl.ExtensionSet{})
+)
+
+// skippedFields are reported separately or duplicate other fields.
+var skippedFields = map[string]bool{"Devices": true, "Info_errors": true, "Parsed_version": true, "Parsed_c_version": true}
+
+// structFields flattens the exported fields of a platform or device into printable values.
+// Nested structs like VectorWidths become "Preferred_vector_width.Char" etc.
+func structFields(v any) fields {
+	var list fields
+	appendStructFields(&list, "", reflect.Indirect(reflect.ValueOf(v)))
+	return list
+}
+
+func appendStructFields(list *fields, prefix string, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		var structField = v.Type().Field(i)
+		var fv = v.Field(i)
+		if !structField.IsExported() || skippedFields[structField.Name] {
+			continue
+		}
+		switch structField.Type {
+		case deviceIDType, platformIDType, extensionsType:
+			continue
+		}
+
+		var name = prefix + structField.Name
+		switch {
+		case structField.Type.Implements(stringerType):
+			*list = append(*list, field{name, fv.Interface().(fmt.Stringer).String()})
+		case fv.Kind() == reflect.Struct:
+			appendStructFields(list, name+".", fv)
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Int:
+			*list = append(*list, field{name, fv.Interface()})
+		case fv.Kind() == reflect.Slice:
+			var values = []string{}
+			for j := 0; j < fv.Len(); j++ {
+				values = append(values, formatValue(fv.Index(j)))
+			}
+			*list = append(*list, field{name, values})
+		default:
+			*list = append(*list, field{name, fv.Interface()})
+		}
+	}
+}
+
+func formatValue(v reflect.Value) string {
+	if nameVersion, ok := v.Interface().(cl.NameVersion); ok {
+		return nameVersion.Name + " " + nameVersion.Version.String()
+	}
+	if v.Type().Implements(stringerType) {
+		return v.Interface().(fmt.Stringer).String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// deviceFields returns the fields of a device followed by the queries that failed.
+func deviceFields(device *cl.OpenCLDevice) fields {
+	var list = structFields(device)
+	if len(device.Info_errors) > 0 {
+		var names = make([]string, 0, len(device.Info_errors))
+		for name := range device.Info_errors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var errs = make([]string, len(names))
+		for i, name := range names {
+			errs[i] = name + ": " + device.Info_errors[name].Error()
+		}
+		list = append(list, field{"Info_errors", errs})
+	}
+	return list
+}
+
+// byteFields are printed with a human readable size in the table view.
+var byteFields = map[string]bool{
+	"Max_mem_alloc_size": true, "Global_mem_size": true, "Local_mem_size": true,
+	"Max_constant_buffer_size": true, "Global_mem_cache_size": true,
+}
+
+// formatField formats a field value as text.
+func formatField(f field, human bool) string {
+	switch value := f.Value.(type) {
+	case []string:
+		return strings.Join(value, " ")
+	case uint64:
+		if human && byteFields[f.Name] {
+			return fmt.Sprintf("%d (%s)", value, formatBytes(value))
+		}
+	case []int:
+		var sizes = make([]string, len(value))
+		for i, size := range value {
+			sizes[i] = fmt.Sprint(size)
+		}
+		return strings.Join(sizes, "x")
+	}
+	return fmt.Sprint(f.Value)
+}
+
+// formatBytes formats a size in bytes with a binary unit.
+func formatBytes(size uint64) string {
+	const units = "KMGTPE"
+	if size < 1024 {
+		return fmt.Sprintf("%dB", size)
+	}
+	var value = float64(size)
+	var unit = -1
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0") + string(units[unit]) + "iB"
+}
@@ -1,11 +1,235 @@
+// cl-info prints the OpenCL platforms and devices of the machine.
+//
+// Usage:
+//
+//	cl-info [-json | -kv] [-platform <index|name>] [-device <index|name|platform:device>] [-field <name>]
+//	cl-info build [-options <options>] [-device <selector>] [-kernels <names>] [-arg-info] [-json] file.cl...
+//	cl-info benchmark [-platform <selector>] [-device <selector>] [-sizes <sizes>] [-iterations <n>] [-json]
+//	cl-info replay [-device <selector>] [-json] trace
 package main
 
 import (
-	"github.com/kr/pretty"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
 	cl "github.com/nathanccxv/go-opencl"
 )
 
 func main() {
-	info, _ := cl.Info()
-	pretty.Println(info)
+	var args = os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "build":
+			os.Exit(buildCommand(args[1:], os.Stdout, os.Stderr))
+		case "benchmark":
+			os.Exit(benchmarkCommand(args[1:], os.Stdout, os.Stderr))
+		case "replay":
+			os.Exit(replayCommand(args[1:], os.Stdout, os.Stderr))
+		}
+	}
+	os.Exit(infoCommand(args, os.Stdout, os.Stderr))
+}
+
+// selection is a platform with the selected devices.
+type selection struct {
+	index    int
+	platform *cl.OpenCLPlatform
+	devices  []selectedDevice
+}
+
+type selectedDevice struct {
+	index  int
+	device *cl.OpenCLDevice
+}
+
+func infoCommand(args []string, stdout, stderr io.Writer) int {
+	var flags = flag.NewFlagSet("cl-info", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var jsonOutput = flags.Bool("json", false, "print JSON")
+	var kvOutput = flags.Bool("kv", false, "print one \"key: value\" line per field")
+	var platformSel = flags.String("platform", "", "only show the platform with this `index or name`")
+	var deviceSel = flags.String("device", "", "only show the device with this `index, name or platform:device` index")
+	var fieldName = flags.String("field", "", "only print the value of this field, e.g. Global_mem_size")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	info, err := loadInfo()
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info:", err)
+		return 1
+	}
+	selections, err := selectInfo(info, *platformSel, *deviceSel)
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info:", err)
+		return 2
+	}
+
+	switch {
+	case *fieldName != "":
+		err = printField(stdout, selections, *fieldName)
+	case *jsonOutput:
+		err = printJSON(stdout, info, selections)
+	case *kvOutput:
+		printKeyValues(stdout, selections)
+	default:
+		printTable(stdout, info, selections)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info:", err)
+		return 2
+	}
+	return 0
+}
+
+// loadInfo returns the OpenCL platforms and devices. A machine without an OpenCL driver has no platforms,
+// which the ICD loader reports as CL_PLATFORM_NOT_FOUND_KHR.
+func loadInfo() (*cl.OpenCLInfo, error) {
+	info, err := cl.Info()
+	if errors.Is(err, cl.ErrPlatformNotFoundKHR) {
+		return &cl.OpenCLInfo{}, nil
+	}
+	return info, err
+}
+
+// selectInfo applies the -platform and -device selectors.
+// A selector is an index, a case-insensitive substring of the name, or for devices "<platform>:<device>".
+func selectInfo(info *cl.OpenCLInfo, platformSel, deviceSel string) ([]selection, error) {
+	if platformSel == "" && strings.Contains(deviceSel, ":") {
+		platformSel, deviceSel, _ = strings.Cut(deviceSel, ":")
+	}
+
+	var selections []selection
+	for i, platform := range info.Platforms {
+		if !matchSelector(platformSel, i, platform.Name) {
+			continue
+		}
+		var s = selection{index: i, platform: platform}
+		for j, device := range platform.Devices {
+			if matchSelector(deviceSel, j, device.Name) {
+				s.devices = append(s.devices, selectedDevice{j, device})
+			}
+		}
+		if deviceSel == "" || len(s.devices) > 0 {
+			selections = append(selections, s)
+		}
+	}
+	if len(selections) == 0 && (platformSel != "" || deviceSel != "") {
+		return nil, fmt.Errorf("no device matches -platform %q -device %q", platformSel, deviceSel)
+	}
+	return selections, nil
+}
+
+func matchSelector(selector string, index int, name string) bool {
+	if selector == "" {
+		return true
+	}
+	if n, err := strconv.Atoi(selector); err == nil {
+		return n == index
+	}
+	return strings.Contains(strings.ToLower(name), strings.ToLower(selector))
+}
+
+// printField prints the value of a device field, or of a platform field, once per selected device or platform.
+func printField(w io.Writer, selections []selection, name string) error {
+	var found bool
+	for _, s := range selections {
+		var printed bool
+		for _, d := range s.devices {
+			if f, ok := deviceFields(d.device).lookup(name); ok {
+				fmt.Fprintln(w, formatField(f, false))
+				printed = true
+			}
+		}
+		if !printed {
+			if f, ok := structFields(s.platform).lookup(name); ok {
+				fmt.Fprintln(w, formatField(f, false))
+				printed = true
+			}
+		}
+		found = found || printed
+	}
+	if !found {
+		return fmt.Errorf("unknown field %q", name)
+	}
+	return nil
+}
+
+type jsonInfo struct {
+	Platforms   []jsonPlatform `json:"platforms"`
+	Diagnostics []string       `json:"diagnostics,omitempty"`
+}
+
+type jsonPlatform struct {
+	Index   int          `json:"index"`
+	Info    fields       `json:"info"`
+	Devices []jsonDevice `json:"devices"`
+}
+
+type jsonDevice struct {
+	Index int    `json:"index"`
+	Info  fields `json:"info"`
+}
+
+func printJSON(w io.Writer, info *cl.OpenCLInfo, selections []selection) error {
+	var out = jsonInfo{Platforms: []jsonPlatform{}}
+	for _, s := range selections {
+		var platform = jsonPlatform{Index: s.index, Info: structFields(s.platform), Devices: []jsonDevice{}}
+		for _, d := range s.devices {
+			platform.Devices = append(platform.Devices, jsonDevice{Index: d.index, Info: deviceFields(d.device)})
+		}
+		out.Platforms = append(out.Platforms, platform)
+	}
+	for _, diag := range info.Diagnostics {
+		out.Diagnostics = append(out.Diagnostics, diag.Error())
+	}
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+func printKeyValues(w io.Writer, selections []selection) {
+	for _, s := range selections {
+		var prefix = fmt.Sprintf("platform.%d.", s.index)
+		for _, f := range structFields(s.platform) {
+			fmt.Fprintf(w, "%s%s: %s\n", prefix, f.Name, formatField(f, false))
+		}
+		for _, d := range s.devices {
+			var prefix = fmt.Sprintf("platform.%d.device.%d.", s.index, d.index)
+			for _, f := range deviceFields(d.device) {
+				fmt.Fprintf(w, "%s%s: %s\n", prefix, f.Name, formatField(f, false))
+			}
+		}
+	}
+}
+
+func printTable(w io.Writer, info *cl.OpenCLInfo, selections []selection) {
+	var tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, s := range selections {
+		fmt.Fprintf(tw, "Platform #%d: %s\n", s.index, s.platform.Name)
+		for _, f := range structFields(s.platform) {
+			fmt.Fprintf(tw, "  %s\t%s\n", f.Name, formatField(f, true))
+		}
+		for _, d := range s.devices {
+			fmt.Fprintf(tw, "\n  Device #%d: %s\n", d.index, d.device.Name)
+			for _, f := range deviceFields(d.device) {
+				fmt.Fprintf(tw, "    %s\t%s\n", f.Name, formatField(f, true))
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	if len(info.Diagnostics) > 0 {
+		fmt.Fprintln(tw, "Diagnostics:")
+		for _, diag := range info.Diagnostics {
+			fmt.Fprintf(tw, "  %v\n", diag)
+		}
+	}
+	tw.Flush()
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	cl "github.com/nathanccxv/go-opencl"
+)
+
+func testInfo() *cl.OpenCLInfo {
+	return &cl.OpenCLInfo{Platforms: []*cl.OpenCLPlatform{
+		{Name: "NVIDIA CUDA", Version: "OpenCL 3.0 CUDA 12.2.138", Devices: []*cl.OpenCLDevice{
+			{Name: "NVIDIA GeForce RTX 3060", Device_type: cl.DeviceTypeGPU, Global_mem_size: 6 << 30,
+				Max_work_item_sizes: []int{1024, 1024, 64}, Extensions: []string{"cl_khr_fp64"}},
+		}},
+		{Name: "Portable Computing Language", Devices: []*cl.OpenCLDevice{
+			{Name: "cpu-haswell", Device_type: cl.DeviceTypeCPU, Global_mem_size: 32 << 30},
+		}},
+	}}
+}
+
+func TestSelectInfo(t *testing.T) {
+	var info = testInfo()
+	for _, test := range []struct {
+		platform, device string
+		want             string
+	}{
+		{"", "", "NVIDIA GeForce RTX 3060"},
+		{"1", "", "cpu-haswell"},
+		{"", "1:0", "cpu-haswell"},
+		{"", "rtx", "NVIDIA GeForce RTX 3060"},
+		{"portable", "0", "cpu-haswell"},
+	} {
+		selections, err := selectInfo(info, test.platform, test.device)
+		if err != nil || selections[0].devices[0].device.Name != test.want {
+			t.Errorf("selectInfo(%q, %q) = %v, %v, want %s", test.platform, test.device, selections, err, test.want)
+		}
+	}
+	if _, err := selectInfo(info, "", "amd"); err == nil {
+		t.Error("selectInfo accepted a missing device")
+	}
+}
+
+func TestPrintField(t *testing.T) {
+	var selections, _ = selectInfo(testInfo(), "", "")
+	for name, want := range map[string]string{
+		"Global_mem_size":     "6442450944\n34359738368\n",
+		"device_type":         "GPU\nCPU\n",
+		"Max_work_item_sizes": "1024x1024x64\n\n",
+	} {
+		var buf bytes.Buffer
+		if err := printField(&buf, selections, name); err != nil || buf.String() != want {
+			t.Errorf("printField(%s) = %q, %v, want %q", name, buf.String(), err, want)
+		}
+	}
+	if err := printField(&bytes.Buffer{}, selections, "No_such_field"); err == nil {
+		t.Error("printField accepted an unknown field")
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	var info = testInfo()
+	var selections, _ = selectInfo(info, "", "0:0")
+	var buf bytes.Buffer
+	if err := printJSON(&buf, info, selections); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Platforms []struct {
+			Devices []struct {
+				Info map[string]any
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err, buf.String())
+	}
+	var device = out.Platforms[0].Devices[0].Info
+	if device["Global_mem_size"] != float64(6<<30) || device["Device_type"] != "GPU" || device["Preferred_vector_width.Float"] != float64(0) {
+		t.Error("unexpected JSON:", buf.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for size, want := range map[uint64]string{512: "512B", 64 << 10: "64KiB", 6 << 30: "6GiB", 1536 << 20: "1.5GiB"} {
+		if got := formatBytes(size); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+func TestInfoCommand(t *testing.T) {
+	// a machine without an OpenCL driver has no platforms, which is not an error
+	for _, args := range [][]string{nil, {"-json"}} {
+		var stdout, stderr bytes.Buffer
+		if status := infoCommand(args, &stdout, &stderr); status != 0 {
+			t.Fatalf("cl-info %q exited with %d: %s", args, status, stderr.String())
+		}
+		if len(args) > 0 && !json.Valid(stdout.Bytes()) {
+			t.Errorf("cl-info -json printed %q", stdout.String())
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cl "github.com/nathanccxv/go-opencl"
+)
+
+// replayResult is the outcome of the replay command, also printed as JSON.
+type replayResult struct {
+	Trace      string   `json:"trace"`
+	RecordedOn []string `json:"recorded_on"`
+	Device     string   `json:"device"`
+	Records    int      `json:"records"`
+	ReadBacks  int      `json:"read_backs"`
+	Diffs      []string `json:"diffs,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// replayCommand re-executes a trace recorded with cl.WithTrace on a device and diffs the data read back.
+// It exits with 1 if the replay fails or any read-back differs.
+func replayCommand(args []string, stdout, stderr io.Writer) int {
+	var flags = flag.NewFlagSet("cl-info replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cl-info replay [flags] trace")
+		flags.PrintDefaults()
+	}
+	var deviceSel = flags.String("device", "", "replay on the device with this `index, name or platform:device` index instead of the best one")
+	var jsonOutput = flags.Bool("json", false, "print JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info replay:", err)
+		return 2
+	}
+	defer file.Close()
+	trace, err := cl.ReadTrace(file)
+	if trace == nil {
+		fmt.Fprintln(stderr, "cl-info replay:", err)
+		return 2
+	}
+	if err != nil {
+		// a truncated trace is replayed as far as it goes
+		fmt.Fprintln(stderr, "cl-info replay: warning:", err)
+	}
+
+	device, err := buildDevice(*deviceSel)
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info replay:", err)
+		return 2
+	}
+	runner, err := device.InitRunner(cl.WithQueues(max(trace.Header.Queues, 1)))
+	if err != nil {
+		fmt.Fprintln(stderr, "cl-info replay:", err)
+		return 1
+	}
+	defer runner.Free()
+
+	var result = replayResult{Trace: flags.Arg(0), RecordedOn: trace.Header.Devices, Device: device.Name,
+		Records: len(trace.Records), ReadBacks: countReadBacks(trace)}
+	diffs, err := trace.Replay(runner)
+	for _, diff := range diffs {
+		result.Diffs = append(result.Diffs, diff.String())
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	if *jsonOutput {
+		var encoder = json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(result)
+	} else {
+		printReplayResult(stdout, result)
+	}
+	if result.Error != "" || len(result.Diffs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// countReadBacks returns the number of records whose data Replay compares.
+func countReadBacks(trace *cl.Trace) int {
+	var count int
+	for _, record := range trace.Records {
+		if record.Err != "" {
+			continue
+		}
+		switch record.Op {
+		case cl.TraceReadBuffer:
+			if record.Blocking {
+				count++
+			}
+		case cl.TraceReadResult, cl.TraceMapBuffer:
+			count++
+		}
+	}
+	return count
+}
+
+func printReplayResult(w io.Writer, result replayResult) {
+	fmt.Fprintf(w, "replayed %d records of %s, recorded on %s, on %s\n",
+		result.Records, result.Trace, strings.Join(result.RecordedOn, ", "), result.Device)
+	for _, diff := range result.Diffs {
+		fmt.Fprintf(w, "  %s\n", diff)
+	}
+	if result.Error != "" {
+		fmt.Fprintf(w, "replay failed: %s\n", result.Error)
+		return
+	}
+	fmt.Fprintf(w, "%d of %d read-backs differ\n", len(result.Diffs), result.ReadBacks)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintReplayResult(t *testing.T) {
+	var result = replayResult{Trace: "run.trace", RecordedOn: []string{"gpu0"}, Device: "gpu1", Records: 12, ReadBacks: 3,
+		Diffs: []string{"record 5: ReadBuffer of buffer 3 at offset 0, 32 bytes: 4 bytes differ, first at 0"}}
+	var buf bytes.Buffer
+	printReplayResult(&buf, result)
+	var want = "replayed 12 records of run.trace, recorded on gpu0, on gpu1\n" +
+		"  record 5: ReadBuffer of buffer 3 at offset 0, 32 bytes: 4 bytes differ, first at 0\n" +
+		"1 of 3 read-backs differ\n"
+	if buf.String() != want {
+		t.Errorf("printReplayResult = %q, want %q", buf.String(), want)
+	}
+}
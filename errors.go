@@ -0,0 +1,221 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ErrorCode is an OpenCL status code as returned by the OpenCL API.
+// The Err* values can be used as sentinels with errors.Is.
+type ErrorCode int32
+
+const (
+	ErrDeviceNotFound                     ErrorCode = -1
+	ErrDeviceNotAvailable                 ErrorCode = -2
+	ErrCompilerNotAvailable               ErrorCode = -3
+	ErrMemObjectAllocationFailure         ErrorCode = -4
+	ErrOutOfResources                     ErrorCode = -5
+	ErrOutOfHostMemory                    ErrorCode = -6
+	ErrProfilingInfoNotAvailable          ErrorCode = -7
+	ErrMemCopyOverlap                     ErrorCode = -8
+	ErrImageFormatMismatch                ErrorCode = -9
+	ErrImageFormatNotSupported            ErrorCode = -10
+	ErrBuildProgramFailure                ErrorCode = -11
+	ErrMapFailure                         ErrorCode = -12
+	ErrMisalignedSubBufferOffset          ErrorCode = -13
+	ErrExecStatusErrorForEventsInWaitList ErrorCode = -14
+	ErrCompileProgramFailure              ErrorCode = -15
+	ErrLinkerNotAvailable                 ErrorCode = -16
+	ErrLinkProgramFailure                 ErrorCode = -17
+	ErrDevicePartitionFailed              ErrorCode = -18
+	ErrKernelArgInfoNotAvailable          ErrorCode = -19
+	ErrInvalidValue                       ErrorCode = -30
+	ErrInvalidDeviceType                  ErrorCode = -31
+	ErrInvalidPlatform                    ErrorCode = -32
+	ErrInvalidDevice                      ErrorCode = -33
+	ErrInvalidContext                     ErrorCode = -34
+	ErrInvalidQueueProperties             ErrorCode = -35
+	ErrInvalidCommandQueue                ErrorCode = -36
+	ErrInvalidHostPtr                     ErrorCode = -37
+	ErrInvalidMemObject                   ErrorCode = -38
+	ErrInvalidImageFormatDescriptor       ErrorCode = -39
+	ErrInvalidImageSize                   ErrorCode = -40
+	ErrInvalidSampler                     ErrorCode = -41
+	ErrInvalidBinary                      ErrorCode = -42
+	ErrInvalidBuildOptions                ErrorCode = -43
+	ErrInvalidProgram                     ErrorCode = -44
+	ErrInvalidProgramExecutable           ErrorCode = -45
+	ErrInvalidKernelName                  ErrorCode = -46
+	ErrInvalidKernelDefinition            ErrorCode = -47
+	ErrInvalidKernel                      ErrorCode = -48
+	ErrInvalidArgIndex                    ErrorCode = -49
+	ErrInvalidArgValue                    ErrorCode = -50
+	ErrInvalidArgSize                     ErrorCode = -51
+	ErrInvalidKernelArgs                  ErrorCode = -52
+	ErrInvalidWorkDimension               ErrorCode = -53
+	ErrInvalidWorkGroupSize               ErrorCode = -54
+	ErrInvalidWorkItemSize                ErrorCode = -55
+	ErrInvalidGlobalOffset                ErrorCode = -56
+	ErrInvalidEventWaitList               ErrorCode = -57
+	ErrInvalidEvent                       ErrorCode = -58
+	ErrInvalidOperation                   ErrorCode = -59
+	ErrInvalidGLObject                    ErrorCode = -60
+	ErrInvalidBufferSize                  ErrorCode = -61
+	ErrInvalidMipLevel                    ErrorCode = -62
+	ErrInvalidGlobalWorkSize              ErrorCode = -63
+	ErrInvalidProperty                    ErrorCode = -64
+	ErrInvalidImageDescriptor             ErrorCode = -65
+	ErrInvalidCompilerOptions             ErrorCode = -66
+	ErrInvalidLinkerOptions               ErrorCode = -67
+	ErrInvalidDevicePartitionCount        ErrorCode = -68
+	ErrInvalidPipeSize                    ErrorCode = -69
+	ErrInvalidDeviceQueue                 ErrorCode = -70
+	ErrInvalidSpecID                      ErrorCode = -71
+	ErrMaxSizeRestrictionExceeded         ErrorCode = -72
+	ErrInvalidGLSharegroupReferenceKHR    ErrorCode = -1000
+	ErrPlatformNotFoundKHR                ErrorCode = -1001
+)
+
+var errorNames = map[ErrorCode]string{
+	0:                                     "CL_SUCCESS",
+	ErrDeviceNotFound:                     "CL_DEVICE_NOT_FOUND",
+	ErrDeviceNotAvailable:                 "CL_DEVICE_NOT_AVAILABLE",
+	ErrCompilerNotAvailable:               "CL_COMPILER_NOT_AVAILABLE",
+	ErrMemObjectAllocationFailure:         "CL_MEM_OBJECT_ALLOCATION_FAILURE",
+	ErrOutOfResources:                     "CL_OUT_OF_RESOURCES",
+	ErrOutOfHostMemory:                    "CL_OUT_OF_HOST_MEMORY",
+	ErrProfilingInfoNotAvailable:          "CL_PROFILING_INFO_NOT_AVAILABLE",
+	ErrMemCopyOverlap:                     "CL_MEM_COPY_OVERLAP",
+	ErrImageFormatMismatch:                "CL_IMAGE_FORMAT_MISMATCH",
+	ErrImageFormatNotSupported:            "CL_IMAGE_FORMAT_NOT_SUPPORTED",
+	ErrBuildProgramFailure:                "CL_BUILD_PROGRAM_FAILURE",
+	ErrMapFailure:                         "CL_MAP_FAILURE",
+	ErrMisalignedSubBufferOffset:          "CL_MISALIGNED_SUB_BUFFER_OFFSET",
+	ErrExecStatusErrorForEventsInWaitList: "CL_EXEC_STATUS_ERROR_FOR_EVENTS_IN_WAIT_LIST",
+	ErrCompileProgramFailure:              "CL_COMPILE_PROGRAM_FAILURE",
+	ErrLinkerNotAvailable:                 "CL_LINKER_NOT_AVAILABLE",
+	ErrLinkProgramFailure:                 "CL_LINK_PROGRAM_FAILURE",
+	ErrDevicePartitionFailed:              "CL_DEVICE_PARTITION_FAILED",
+	ErrKernelArgInfoNotAvailable:          "CL_KERNEL_ARG_INFO_NOT_AVAILABLE",
+	ErrInvalidValue:                       "CL_INVALID_VALUE",
+	ErrInvalidDeviceType:                  "CL_INVALID_DEVICE_TYPE",
+	ErrInvalidPlatform:                    "CL_INVALID_PLATFORM",
+	ErrInvalidDevice:                      "CL_INVALID_DEVICE",
+	ErrInvalidContext:                     "CL_INVALID_CONTEXT",
+	ErrInvalidQueueProperties:             "CL_INVALID_QUEUE_PROPERTIES",
+	ErrInvalidCommandQueue:                "CL_INVALID_COMMAND_QUEUE",
+	ErrInvalidHostPtr:                     "CL_INVALID_HOST_PTR",
+	ErrInvalidMemObject:                   "CL_INVALID_MEM_OBJECT",
+	ErrInvalidImageFormatDescriptor:       "CL_INVALID_IMAGE_FORMAT_DESCRIPTOR",
+	ErrInvalidImageSize:                   "CL_INVALID_IMAGE_SIZE",
+	ErrInvalidSampler:                     "CL_INVALID_SAMPLER",
+	ErrInvalidBinary:                      "CL_INVALID_BINARY",
+	ErrInvalidBuildOptions:                "CL_INVALID_BUILD_OPTIONS",
+	ErrInvalidProgram:                     "CL_INVALID_PROGRAM",
+	ErrInvalidProgramExecutable:           "CL_INVALID_PROGRAM_EXECUTABLE",
+	ErrInvalidKernelName:                  "CL_INVALID_KERNEL_NAME",
+	ErrInvalidKernelDefinition:            "CL_INVALID_KERNEL_DEFINITION",
+	ErrInvalidKernel:                      "CL_INVALID_KERNEL",
+	ErrInvalidArgIndex:                    "CL_INVALID_ARG_INDEX",
+	ErrInvalidArgValue:                    "CL_INVALID_ARG_VALUE",
+	ErrInvalidArgSize:                     "CL_INVALID_ARG_SIZE",
+	ErrInvalidKernelArgs:                  "CL_INVALID_KERNEL_ARGS",
+	ErrInvalidWorkDimension:               "CL_INVALID_WORK_DIMENSION",
+	ErrInvalidWorkGroupSize:               "CL_INVALID_WORK_GROUP_SIZE",
+	ErrInvalidWorkItemSize:                "CL_INVALID_WORK_ITEM_SIZE",
+	ErrInvalidGlobalOffset:                "CL_INVALID_GLOBAL_OFFSET",
+	ErrInvalidEventWaitList:               "CL_INVALID_EVENT_WAIT_LIST",
+	ErrInvalidEvent:                       "CL_INVALID_EVENT",
+	ErrInvalidOperation:                   "CL_INVALID_OPERATION",
+	ErrInvalidGLObject:                    "CL_INVALID_GL_OBJECT",
+	ErrInvalidBufferSize:                  "CL_INVALID_BUFFER_SIZE",
+	ErrInvalidMipLevel:                    "CL_INVALID_MIP_LEVEL",
+	ErrInvalidGlobalWorkSize:              "CL_INVALID_GLOBAL_WORK_SIZE",
+	ErrInvalidProperty:                    "CL_INVALID_PROPERTY",
+	ErrInvalidImageDescriptor:             "CL_INVALID_IMAGE_DESCRIPTOR",
+	ErrInvalidCompilerOptions:             "CL_INVALID_COMPILER_OPTIONS",
+	ErrInvalidLinkerOptions:               "CL_INVALID_LINKER_OPTIONS",
+	ErrInvalidDevicePartitionCount:        "CL_INVALID_DEVICE_PARTITION_COUNT",
+	ErrInvalidPipeSize:                    "CL_INVALID_PIPE_SIZE",
+	ErrInvalidDeviceQueue:                 "CL_INVALID_DEVICE_QUEUE",
+	ErrInvalidSpecID:                      "CL_INVALID_SPEC_ID",
+	ErrMaxSizeRestrictionExceeded:         "CL_MAX_SIZE_RESTRICTION_EXCEEDED",
+	ErrInvalidGLSharegroupReferenceKHR:    "CL_INVALID_GL_SHAREGROUP_REFERENCE_KHR",
+	ErrPlatformNotFoundKHR:                "CL_PLATFORM_NOT_FOUND_KHR",
+}
+
+// Name returns the symbolic name of the status code, e.g. "CL_INVALID_KERNEL_ARGS".
+func (code ErrorCode) Name() string {
+	if name, ok := errorNames[code]; ok {
+		return name
+	}
+	return "CL_UNKNOWN_ERROR(" + strconv.Itoa(int(code)) + ")"
+}
+
+func (code ErrorCode) Error() string {
+	return code.Name()
+}
+
+// Error describes a failed OpenCL API call.
+// It unwraps to its ErrorCode, so errors.Is(err, ErrOutOfResources) works on any error returned by this package.
+type Error struct {
+	Code ErrorCode
+	Name string
+	// Func is the OpenCL API call that failed, e.g. "clEnqueueNDRangeKernel".
+	Func string
+	// Kernel is the name of the kernel involved, if any.
+	Kernel string
+	// Buffer is the buffer involved, if any.
+	Buffer *Buffer
+}
+
+func (e *Error) Error() string {
+	var msg = fmt.Sprintf("%s Err: %s (%d)", e.Func, e.Name, e.Code)
+	if e.Kernel != "" {
+		msg += fmt.Sprintf(" kernel %q", e.Kernel)
+	}
+	if e.Buffer != nil {
+		msg += fmt.Sprintf(" buffer of %d bytes", e.Buffer.size)
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Code
+}
+
+// clError wraps a failed cl_int status returned by the OpenCL API call fn.
+func clError(fn string, err C.cl_int) *Error {
+	return codeError(fn, ErrorCode(err))
+}
+
+// codeError is clError for status codes that do not come from the OpenCL library, e.g. of the fake backend.
+func codeError(fn string, code ErrorCode) *Error {
+	return &Error{Code: code, Name: code.Name(), Func: fn}
+}
+
+// kernelError is clError for calls that operate on a named kernel.
+func kernelError(fn string, err C.cl_int, kernelName string) *Error {
+	var e = clError(fn, err)
+	e.Kernel = kernelName
+	return e
+}
+
+// withKernel sets the kernel name of an *Error returned by a backend.
+func withKernel(err error, kernelName string) error {
+	if e, ok := err.(*Error); ok && e.Kernel == "" {
+		e.Kernel = kernelName
+	}
+	return err
+}
+
+// withBuffer sets the buffer of an *Error returned by a backend.
+func withBuffer(err error, buffer *Buffer) error {
+	if e, ok := err.(*Error); ok && e.Buffer == nil {
+		e.Buffer = buffer
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package opencl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorCode(t *testing.T) {
+	var err error = &Error{Code: ErrInvalidWorkGroupSize, Name: ErrInvalidWorkGroupSize.Name(),
+		Func: "clEnqueueNDRangeKernel", Kernel: "helloworld"}
+
+	if !errors.Is(err, ErrInvalidWorkGroupSize) {
+		t.Fatal("errors.Is failed:", err)
+	}
+	if errors.Is(err, ErrOutOfResources) {
+		t.Fatal("errors.Is matched the wrong code:", err)
+	}
+
+	var clErr *Error
+	if !errors.As(err, &clErr) || clErr.Kernel != "helloworld" {
+		t.Fatal("errors.As failed:", err)
+	}
+
+	if got := err.Error(); got != `clEnqueueNDRangeKernel Err: CL_INVALID_WORK_GROUP_SIZE (-54) kernel "helloworld"` {
+		t.Fatal("unexpected message:", got)
+	}
+	if got := ErrorCode(-9999).Name(); got != "CL_UNKNOWN_ERROR(-9999)" {
+		t.Fatal("unexpected name:", got)
+	}
+}
+
+func TestBufferError(t *testing.T) {
+	var runner = newFakeTestRunner(t)
+	buffer, err := runner.CreateEmptyBuffer(READ_WRITE, 32)
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+
+	err = ReadBuffer(runner, 16, buffer, make([]int32, 8))
+	var clErr *Error
+	if !errors.As(err, &clErr) || clErr.Buffer != buffer || !errors.Is(err, ErrInvalidValue) {
+		t.Fatal("ReadBuffer past the end of the buffer err:", err)
+	}
+	if got := err.Error(); got != "clEnqueueReadBuffer Err: CL_INVALID_VALUE (-30) buffer of 32 bytes" {
+		t.Fatal("unexpected message:", got)
+	}
+	if _, err = MapBuffer[int32](runner, buffer, MAP_READ, 0, 16); !errors.As(err, &clErr) || clErr.Buffer != buffer {
+		t.Fatal("MapBuffer past the end of the buffer err:", err)
+	}
+}
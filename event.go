@@ -0,0 +1,316 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// EventStatus is the execution status of the command associated with an Event.
+type EventStatus int32
+
+const (
+	Complete  EventStatus = C.CL_COMPLETE
+	Running   EventStatus = C.CL_RUNNING
+	Submitted EventStatus = C.CL_SUBMITTED
+	Queued    EventStatus = C.CL_QUEUED
+)
+
+func (status EventStatus) String() string {
+	switch status {
+	case Complete:
+		return "CL_COMPLETE"
+	case Running:
+		return "CL_RUNNING"
+	case Submitted:
+		return "CL_SUBMITTED"
+	case Queued:
+		return "CL_QUEUED"
+	}
+	if status < 0 {
+		return ErrorCode(status).Name()
+	}
+	return "EventStatus(" + strconv.Itoa(int(status)) + ")"
+}
+
+// Event represents an enqueued command.
+// Events returned by the Enqueue* functions must be released with Release, or are released by the runner's Free.
+type Event struct {
+	event BackendEvent
+	handle
+
+	// pinner keeps the host memory of a non-blocking transfer in place until the command completes.
+	pinner *runtime.Pinner
+
+	// runner and command identify the command in the runner's profiling statistics and timeline.
+	runner *OpenCLRunner
+	command
+	recorded bool
+
+	// onComplete, if set, is called once the command has completed, e.g. to trace the data of a read.
+	onComplete func()
+}
+
+// Wait blocks until the command has completed.
+func (e *Event) Wait() error {
+	if e.event == nil {
+		return codeError("clWaitForEvents", ErrInvalidEvent)
+	}
+	var start = time.Now()
+	var err = e.event.Wait()
+	if e.runner != nil && e.runner.timeline != nil {
+		e.runner.timeline.call("Wait", start, map[string]any{"command": e.name})
+	}
+	if err != nil {
+		return err
+	}
+	e.completed()
+	return nil
+}
+
+// WaitCtx blocks like Wait until the command has completed, but returns ctx.Err() if ctx is done first.
+// The queue of the command is flushed so that it reaches the device, and the completion is awaited with
+// clSetEventCallback, or by polling the status if callbacks are not supported. If the command was terminated
+// abnormally, the returned error is the ErrorCode it failed with.
+//
+// OpenCL commands cannot be cancelled: when ctx is done, the command stays enqueued and may still run,
+// and the commands enqueued after it on an in-order queue still wait for it. The event remains valid and
+// may be waited for again; releasing the event of a non-blocking transfer still waits for it to complete.
+func (e *Event) WaitCtx(ctx context.Context) error {
+	if e.event == nil {
+		return codeError("clWaitForEvents", ErrInvalidEvent)
+	}
+	if ctx.Done() == nil {
+		return e.Wait()
+	}
+	var start = time.Now()
+	var err = e.waitCtx(ctx)
+	if e.runner != nil && e.runner.timeline != nil {
+		e.runner.timeline.call("Wait", start, map[string]any{"command": e.name})
+	}
+	if err != nil {
+		return err
+	}
+	e.completed()
+	return nil
+}
+
+func (e *Event) waitCtx(ctx context.Context) error {
+	status, err := e.event.Status()
+	if err != nil || status == Complete || status < 0 {
+		return statusError(status, err)
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if e.queue != nil {
+		if err = e.queue.Flush(); err != nil {
+			return err
+		}
+	}
+	select {
+	case err = <-eventDone(ctx, e.event, e.event.Status):
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// eventDone returns a channel that receives the outcome of the command once it has completed.
+// If the backend cannot notify it, the status is polled until ctx is done or poll fails.
+func eventDone(ctx context.Context, event BackendEvent, poll func() (EventStatus, error)) <-chan error {
+	var done = make(chan error, 1)
+	if event.Notify(func(status EventStatus) { done <- statusError(status, nil) }) == nil {
+		return done
+	}
+	go func() {
+		var delay = 50 * time.Microsecond
+		for {
+			status, err := poll()
+			if err != nil || status == Complete || status < 0 {
+				done <- statusError(status, err)
+				return
+			}
+			select {
+			case <-time.After(delay):
+				delay = min(2*delay, 10*time.Millisecond)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// statusError returns err, or the ErrorCode of a command that was terminated abnormally.
+func statusError(status EventStatus, err error) error {
+	if err == nil && status < 0 {
+		return ErrorCode(status)
+	}
+	return err
+}
+
+// waitRelease waits for an event the caller did not ask for and releases it.
+// If ctx is done first, the event is released once the command has completed instead.
+func (e *Event) waitRelease(ctx context.Context) error {
+	var err = e.WaitCtx(ctx)
+	if err != nil && err == ctx.Err() && e.runner != nil {
+		e.releaseWhenComplete()
+		return err
+	}
+	e.Release()
+	return err
+}
+
+// releaseWhenComplete releases the event in the background once its command has completed, which the completion
+// callback of the backend signals, or polling its status otherwise. Its host memory stays pinned until then.
+// The runner may be freed meanwhile: runner.releasing keeps Free from releasing the event while it is used here.
+func (e *Event) releaseWhenComplete() {
+	var runner = e.runner
+	e.obj.setOwned(true)
+	var poll = func() (EventStatus, error) {
+		runner.releasing.RLock()
+		defer runner.releasing.RUnlock()
+		if !e.obj.alive() {
+			return 0, codeError("clGetEventInfo", ErrInvalidEvent)
+		}
+		return e.event.Status()
+	}
+	var done = eventDone(context.Background(), e.event, poll)
+	go func() {
+		<-done
+		runner.releasing.RLock()
+		defer runner.releasing.RUnlock()
+		if e.obj.alive() {
+			e.completed()
+		}
+		e.Release()
+	}()
+}
+
+// Status returns the execution status of the command.
+// If the command was terminated abnormally, the returned error is the ErrorCode it failed with.
+func (e *Event) Status() (EventStatus, error) {
+	if e.event == nil {
+		return 0, codeError("clGetEventInfo", ErrInvalidEvent)
+	}
+	status, err := e.event.Status()
+	if err != nil {
+		return 0, err
+	}
+	if status < 0 {
+		return status, ErrorCode(status)
+	}
+	if status == Complete {
+		e.completed()
+	}
+	return status, nil
+}
+
+// Release releases the event. Calling Release more than once has no effect.
+// If the event belongs to a non-blocking transfer, Release first waits for it to complete
+// so that the host memory is not used after it is unpinned.
+func (e *Event) Release() error {
+	if e.event == nil {
+		return nil
+	}
+	if e.obj.alive() {
+		if e.pinner != nil && e.event.Wait() == nil {
+			e.completed()
+		}
+		e.record()
+	}
+	e.unpin()
+	e.event = nil
+	return e.releaseHandle()
+}
+
+// Retain returns a new handle of the event that keeps it alive until it is released.
+// Only the original event adds to the runner's profiling statistics.
+func (e *Event) Retain() (*Event, error) {
+	if e.event == nil || !e.obj.retain() {
+		return nil, codeError("clRetainEvent", ErrInvalidEvent)
+	}
+	var retained = &Event{event: e.event, command: e.command}
+	retained.obj = e.obj
+	if e.obj.tracker.finalizers {
+		runtime.SetFinalizer(retained, (*Event).finalize)
+	}
+	return retained, nil
+}
+
+// finalize releases an event that was garbage collected without Release.
+func (e *Event) finalize() {
+	if e.event != nil && e.collected() {
+		e.Release()
+	}
+}
+
+// completed is called once the command is known to have completed.
+func (e *Event) completed() {
+	e.unpin()
+	e.record()
+	if onComplete := e.onComplete; onComplete != nil {
+		e.onComplete = nil
+		onComplete()
+	}
+}
+
+func (e *Event) unpin() {
+	if e.pinner != nil {
+		e.pinner.Unpin()
+		e.pinner = nil
+	}
+}
+
+// record adds the execution time of a completed command to the runner's profiling statistics.
+func (e *Event) record() {
+	if e.recorded || e.runner == nil || !e.runner.profiling {
+		return
+	}
+	if profile, err := e.Profile(); err == nil {
+		e.recorded = true
+		e.runner.recordProfile(e.name, profile.Duration())
+		if e.runner.timeline != nil {
+			e.runner.timeline.command(e, profile)
+		}
+	}
+}
+
+// command describes an enqueued command.
+type command struct {
+	name  string         // the kernel name, "ReadBuffer" or "WriteBuffer"
+	queue *Queue         // the queue it was enqueued on
+	start time.Time      // when it was enqueued
+	args  map[string]any // its details in the timeline, nil unless the runner has one
+}
+
+// WaitAll blocks until all commands have completed. Nil events are ignored.
+func WaitAll(events ...*Event) error {
+	for _, event := range eventWaitList(events) {
+		if err := event.Wait(); err != nil {
+			return err
+		}
+	}
+	for _, e := range events {
+		if e != nil {
+			e.completed()
+		}
+	}
+	return nil
+}
+
+// eventWaitList returns the backend events of events, skipping nil and released events.
+func eventWaitList(events []*Event) []BackendEvent {
+	var eventList []BackendEvent
+	for _, e := range events {
+		if e != nil && e.event != nil {
+			eventList = append(eventList, e.event)
+		}
+	}
+	return eventList
+}
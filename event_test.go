@@ -0,0 +1,175 @@
+package opencl
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowEvent is a backend event whose command completes when complete is called.
+// It is polled unless it has callbacks.
+type slowEvent struct {
+	fakeEvent
+	state     atomic.Int32 // the EventStatus
+	callbacks bool
+	mu        sync.Mutex
+	done      []func(EventStatus)
+}
+
+func newSlowEvent() *slowEvent {
+	var e = &slowEvent{}
+	e.state.Store(int32(Queued))
+	return e
+}
+
+func (e *slowEvent) Status() (EventStatus, error) {
+	return EventStatus(e.state.Load()), nil
+}
+
+func (e *slowEvent) Notify(done func(status EventStatus)) error {
+	if !e.callbacks {
+		return codeError("clSetEventCallback", ErrInvalidOperation)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.done = append(e.done, done)
+	return nil
+}
+
+func (e *slowEvent) complete() {
+	e.state.Store(int32(Complete))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, done := range e.done {
+		done(Complete)
+	}
+	e.done = nil
+}
+
+func TestWaitCtx(t *testing.T) {
+	var runner = newFakeTestRunner(t)
+	var queue = runner.Queues[0]
+
+	var slow = newSlowEvent()
+	var event = runner.enqueued(slow, nil, command{name: "slow", queue: queue}, false, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := event.WaitCtx(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("WaitCtx of a queued command = %v, want %v", err, context.DeadlineExceeded)
+	}
+	go func(slow *slowEvent) {
+		time.Sleep(5 * time.Millisecond)
+		slow.complete()
+	}(slow)
+	if err := event.WaitCtx(context.WithoutCancel(ctx)); err != nil {
+		t.Fatal("WaitCtx after the command completed err:", err)
+	}
+	event.Release()
+
+	slow = newSlowEvent()
+	slow.state.Store(int32(ErrOutOfResources))
+	event = runner.enqueued(slow, nil, command{name: "failed", queue: queue}, false, true)
+	if err := event.WaitCtx(ctx); !errors.Is(err, ErrOutOfResources) {
+		t.Errorf("WaitCtx of a failed command = %v, want %v", err, ErrOutOfResources)
+	}
+	event.Release()
+
+	// a command that outlives its context is released once it has completed, by polling or from its callback
+	for _, callbacks := range []bool{false, true} {
+		slow = newSlowEvent()
+		slow.callbacks = callbacks
+		event = runner.enqueued(slow, nil, command{name: "cancelled", queue: queue}, false, true)
+		cancelled, cancelNow := context.WithCancel(context.Background())
+		cancelNow()
+		if err := event.waitRelease(cancelled); err != context.Canceled {
+			t.Fatalf("waitRelease with a cancelled context = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+		if !event.obj.alive() {
+			t.Fatalf("the event of a pending command was released (callbacks %v)", callbacks)
+		}
+		slow.complete()
+		for i := 0; i < 100 && event.obj.alive(); i++ {
+			time.Sleep(time.Millisecond)
+		}
+		if event.obj.alive() {
+			t.Errorf("the event of a completed command was not released (callbacks %v)", callbacks)
+		}
+	}
+	if len(runner.pendingEvents) != 0 {
+		t.Errorf("%d pending events", len(runner.pendingEvents))
+	}
+
+	// Free does not wait for a command that never completes
+	slow = newSlowEvent()
+	event = runner.enqueued(slow, nil, command{name: "hung", queue: queue}, false, true)
+	if err := event.waitRelease(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("waitRelease of a hung command = %v", err)
+	}
+	if err := runner.Free(); err != nil {
+		t.Fatal("Free err:", err)
+	}
+	if event.obj.alive() {
+		t.Error("Free did not release the event of the hung command")
+	}
+}
+
+func TestRunKernelCtx(t *testing.T) {
+	var hook recordingHook
+	var spans []any
+	var caller = HookFuncs{BeforeFunc: func(ctx context.Context, op *Operation) context.Context {
+		spans = append(spans, ctx.Value(spanKey{}))
+		return ctx
+	}}
+	var runner = newFakeTestRunner(t, WithHooks(caller, &hook))
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), spanKey{}, "request"), time.Second)
+	defer cancel()
+
+	input := []int32{1, 2, 3, 4}
+	inputBuf, err := runner.CreateEmptyBuffer(READ_ONLY, 4*len(input))
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	outputBuf, err := runner.CreateEmptyBuffer(WRITE_ONLY, 4*len(input))
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	if err = WriteBufferCtx(ctx, runner, 0, inputBuf, input); err != nil {
+		t.Fatal("WriteBufferCtx err:", err)
+	}
+	var factor int32 = 2
+	err = runner.RunKernelCtx(ctx, "square", 1, nil, []uint64{4}, nil, []KernelParam{
+		BufferParam(inputBuf), BufferParam(outputBuf), Param(&factor),
+	})
+	if err != nil {
+		t.Fatal("RunKernelCtx err:", err)
+	}
+	var output = make([]int32, len(input))
+	if err = ReadBufferCtx(ctx, runner, 0, outputBuf, output); err != nil {
+		t.Fatal("ReadBufferCtx err:", err)
+	}
+	if want := []int32{2, 8, 18, 32}; !slices.Equal(output, want) {
+		t.Errorf("output %v, want %v", output, want)
+	}
+	if err = runner.RunKernelCtx(ctx, "missing", 1, nil, []uint64{4}, nil, nil); !errors.Is(err, ErrInvalidKernelName) {
+		t.Errorf("RunKernelCtx of a missing kernel = %v", err)
+	}
+
+	var names []string
+	for _, op := range hook.ops[1:] {
+		names = append(names, op.Name)
+	}
+	if want := []string{"CreateBuffer", "CreateBuffer", "WriteBuffer", "RunKernel", "ReadBuffer", "RunKernel"}; !slices.Equal(names, want) {
+		t.Fatalf("operations %q, want %q", names, want)
+	}
+	if want := []any{nil, nil, nil, "request", "request", "request", "request"}; !slices.Equal(spans, want) {
+		t.Errorf("Before received the spans %v, want the context of the Ctx calls %v", spans, want)
+	}
+	if op := hook.ops[5]; op.Bytes != 16 {
+		t.Errorf("ReadBuffer bytes %d, want 16", op.Bytes)
+	}
+}
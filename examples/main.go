@@ -11,18 +11,13 @@ import (
 
 // TestRunner is a test function that tests the functionality of the OpenCL runner.
 func main() {
-	// Step 1: Get OpenCL device information
-	info, _ := cl.Info()
-
-	if len(info.Platforms) < 1 {
-		log.Fatal("No OpenCL Devices")
-	}
-	if len(info.Platforms[0].Devices) < 1 {
-		log.Fatal("No OpenCL Devices")
+	// Step 1: Select the fastest OpenCL device, GO_OPENCL_DEVICE=<platform>:<device> overrides the choice
+	device, err := cl.SelectDevice(cl.DeviceFilter{})
+	if err != nil {
+		log.Fatal("No OpenCL Devices: ", err)
 	}
 
 	// Step 2: Initialize the OpenCL runner
-	device := info.Platforms[0].Devices[0]
 	runner, err := device.InitRunner()
 	if err != nil {
 		log.Fatal("InitRunner err:", err)
@@ -0,0 +1,90 @@
+package opencl
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// ExtensionSet is a set of extension or OpenCL C feature names.
+type ExtensionSet map[string]struct{}
+
+// NewExtensionSet returns a set of the given names.
+func NewExtensionSet(names ...string) ExtensionSet {
+	var set = make(ExtensionSet, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return set
+}
+
+// Has reports whether name is in the set.
+func (set ExtensionSet) Has(name string) bool {
+	_, ok := set[name]
+	return ok
+}
+
+// Names returns the names in the set, sorted.
+func (set ExtensionSet) Names() []string {
+	var names = make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NameVersion is an extension or feature with its version, as reported by the OpenCL 3.0 queries.
+type NameVersion struct {
+	Name    string
+	Version Version
+}
+
+// The cl_name_version layout of OpenCL 3.0, which the 1.2 headers used for the build do not define.
+const (
+	clNameVersionMaxNameSize = 64
+	clNameVersionSize        = 4 + clNameVersionMaxNameSize
+	clDeviceOpenCLCFeatures  = 0x106F
+)
+
+// decodeNameVersions decodes an array of cl_name_version.
+func decodeNameVersions(data []byte) []NameVersion {
+	var nameVersions []NameVersion
+	for ; len(data) >= clNameVersionSize; data = data[clNameVersionSize:] {
+		var version = binary.NativeEndian.Uint32(data)
+		var name, _, _ = strings.Cut(string(data[4:clNameVersionSize]), "\x00")
+		nameVersions = append(nameVersions, NameVersion{
+			Name:    name,
+			Version: Version{Major: int(version >> 22), Minor: int(version >> 12 & 0x3ff)},
+		})
+	}
+	return nameVersions
+}
+
+// Supports reports whether the device supports an extension, e.g. "cl_khr_fp64",
+// or an optional OpenCL C 3.0 feature, e.g. "__opencl_c_fp64".
+func (device *OpenCLDevice) Supports(name string) bool {
+	if device.Extension_set.Has(name) {
+		return true
+	}
+	for _, feature := range device.OpenCL_c_features {
+		if feature.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AtLeast reports whether the device supports OpenCL major.minor or newer.
+func (device *OpenCLDevice) AtLeast(major, minor int) bool {
+	return device.Parsed_version.AtLeast(major, minor)
+}
+
+// OpenCLCAtLeast reports whether the device compiler supports OpenCL C major.minor or newer.
+func (device *OpenCLDevice) OpenCLCAtLeast(major, minor int) bool {
+	if device.Parsed_c_version.AtLeast(major, minor) {
+		return true
+	}
+	// OpenCL 3.0 devices report OpenCL C 1.2 for compatibility, but always support OpenCL C 3.0.
+	return major == 3 && minor == 0 && device.AtLeast(3, 0)
+}
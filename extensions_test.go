@@ -0,0 +1,59 @@
+package opencl
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+func TestDecodeNameVersions(t *testing.T) {
+	var data []byte
+	for _, feature := range []struct {
+		name    string
+		version uint32
+	}{
+		{"__opencl_c_fp64", 3<<22 | 0<<12},
+		{"__opencl_c_subgroups", 3<<22 | 1<<12 | 5},
+	} {
+		var entry [clNameVersionSize]byte
+		binary.NativeEndian.PutUint32(entry[:], feature.version)
+		copy(entry[4:], feature.name)
+		data = append(data, entry[:]...)
+	}
+
+	var got = decodeNameVersions(data)
+	var want = []NameVersion{
+		{Name: "__opencl_c_fp64", Version: Version{Major: 3, Minor: 0}},
+		{Name: "__opencl_c_subgroups", Version: Version{Major: 3, Minor: 1}},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("decodeNameVersions = %+v, want %+v", got, want)
+	}
+}
+
+func TestDeviceSupports(t *testing.T) {
+	var device = &OpenCLDevice{
+		Parsed_version:    Version{Major: 3},
+		Parsed_c_version:  Version{Major: 1, Minor: 2},
+		Extension_set:     NewExtensionSet("cl_khr_fp64", "cl_khr_int64_base_atomics"),
+		OpenCL_c_features: []NameVersion{{Name: "__opencl_c_subgroups", Version: Version{Major: 3}}},
+	}
+	for name, want := range map[string]bool{
+		"cl_khr_fp64":          true,
+		"__opencl_c_subgroups": true,
+		"cl_khr_fp16":          false,
+	} {
+		if got := device.Supports(name); got != want {
+			t.Errorf("Supports(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !device.AtLeast(2, 0) || device.AtLeast(3, 1) {
+		t.Error("AtLeast failed")
+	}
+	if !device.OpenCLCAtLeast(1, 2) || !device.OpenCLCAtLeast(3, 0) || device.OpenCLCAtLeast(2, 0) {
+		t.Error("OpenCLCAtLeast failed")
+	}
+	if names := device.Extension_set.Names(); !slices.Equal(names, []string{"cl_khr_fp64", "cl_khr_int64_base_atomics"}) {
+		t.Error("Names =", names)
+	}
+}
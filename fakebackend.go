@@ -0,0 +1,535 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// FakeKernel is the Go implementation of a kernel for FakeBackend.
+// It is called once for each work-item with the arguments of the enqueued kernel.
+type FakeKernel func(item WorkItem, args FakeArgs)
+
+// WorkItem identifies a work-item of an NDRange, as the OpenCL C work-item functions do.
+type WorkItem struct {
+	Dim        int    // get_work_dim
+	GlobalID   [3]int // get_global_id, including the global work offset
+	LocalID    [3]int // get_local_id
+	GroupID    [3]int // get_group_id
+	GlobalSize [3]int // get_global_size
+	LocalSize  [3]int // get_local_size
+	NumGroups  [3]int // get_num_groups
+}
+
+// FakeArgs holds the arguments of a FakeKernel call: the contents of buffers,
+// the local memory shared by the work-items of a work-group, or the bytes of scalar values.
+type FakeArgs [][]byte
+
+// FakeSlice returns an argument as a slice of E, e.g. FakeSlice[float32](args[0]) for a __global float* argument.
+func FakeSlice[E any](arg []byte) []E {
+	var size = int(unsafe.Sizeof(*new(E)))
+	if len(arg) < size {
+		return nil
+	}
+	return unsafe.Slice((*E)(unsafe.Pointer(&arg[0])), len(arg)/size)
+}
+
+// FakeValue returns a scalar argument as E, e.g. FakeValue[int32](args[1]) for an int argument.
+func FakeValue[E any](arg []byte) E {
+	var value E
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&value)), unsafe.Sizeof(value)), arg)
+	return value
+}
+
+// FakeBackend is a Backend that runs kernels written in Go on the host instead of an OpenCL device,
+// so that code built on OpenCLRunner can be tested deterministically without an OpenCL driver.
+//
+// Kernels are registered by name with RegisterKernel; their signatures are taken from the program sources,
+// so Kernel.Args and argument validation work as with -cl-kernel-arg-info.
+// Buffers are host memory, and each command runs to completion when it is enqueued, one at a time,
+// so events are always complete. Work-items run one after the other, so kernels that synchronize
+// work-items with barriers are not supported. A panic in a kernel propagates to the enqueueing goroutine.
+type FakeBackend struct {
+	mu      sync.Mutex
+	kernels map[string]FakeKernel
+	device  *OpenCLDevice
+
+	exec  sync.Mutex // serializes the commands of all queues
+	epoch time.Time  // origin of the profiling timestamps
+}
+
+// NewFakeBackend returns a FakeBackend without kernels.
+func NewFakeBackend() *FakeBackend {
+	return &FakeBackend{
+		kernels: make(map[string]FakeKernel),
+		device:  newFakeDevice(),
+		epoch:   time.Now(),
+	}
+}
+
+func newFakeDevice() *OpenCLDevice {
+	return &OpenCLDevice{
+		Device_type:              DeviceTypeCPU,
+		Name:                     "go-opencl fake device",
+		Profile:                  "FULL_PROFILE",
+		Version:                  "OpenCL 1.2 go-opencl fake",
+		Vendor:                   "go-opencl",
+		Driver_version:           "1.0",
+		Max_clock_frequency:      1,
+		Max_mem_alloc_size:       1 << 30,
+		Global_mem_size:          1 << 32,
+		Max_compute_units:        1,
+		Max_work_group_size:      1024,
+		Max_work_item_dimensions: 3,
+		Max_work_item_sizes:      []int{1024, 1024, 1024},
+		Local_mem_size:           64 << 10,
+		Local_mem_type:           LocalMemGlobal,
+		Max_constant_buffer_size: 64 << 10,
+		Max_constant_args:        8,
+		Mem_base_addr_align:      1024,
+		Max_parameter_size:       1024,
+		Address_bits:             64,
+		Endian_little:            true,
+		Host_unified_memory:      true,
+		Available:                true,
+		Compiler_available:       true,
+		OpenCL_c_version:         "OpenCL C 1.2",
+		Parsed_version:           Version{Major: 1, Minor: 2, Suffix: "go-opencl fake"},
+		Parsed_c_version:         Version{Major: 1, Minor: 2},
+		Extension_set:            NewExtensionSet(),
+	}
+}
+
+// RegisterKernel sets the Go implementation of the kernel with the given name.
+// Kernels can be registered before or after the programs defining them are built.
+func (backend *FakeBackend) RegisterKernel(name string, kernel FakeKernel) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.kernels[name] = kernel
+}
+
+func (backend *FakeBackend) kernel(name string) FakeKernel {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	return backend.kernels[name]
+}
+
+// Device returns the device the backend pretends to be.
+func (backend *FakeBackend) Device() *OpenCLDevice {
+	return backend.device
+}
+
+// InitRunner initializes an OpenCLRunner for the fake device.
+func (backend *FakeBackend) InitRunner(opts ...RunnerOption) (*OpenCLRunner, error) {
+	return backend.device.InitRunner(append(opts[:len(opts):len(opts)], WithBackend(backend))...)
+}
+
+func (backend *FakeBackend) NewContext(devices []*OpenCLDevice, callback ContextCallback) (BackendContext, error) {
+	return &fakeContext{backend: backend}, nil
+}
+
+type fakeContext struct {
+	backend *FakeBackend
+}
+
+func (ctx *fakeContext) Release() ErrorCode {
+	return C.CL_SUCCESS
+}
+
+func (ctx *fakeContext) NewQueue(device *OpenCLDevice, properties QueueProperties) (BackendQueue, error) {
+	return &fakeQueue{backend: ctx.backend, profiling: properties&C.CL_QUEUE_PROFILING_ENABLE != 0}, nil
+}
+
+func (ctx *fakeContext) NewBuffer(flags MemFlags, size int, host unsafe.Pointer) (BackendBuffer, error) {
+	if size <= 0 {
+		return nil, codeError("clCreateBuffer", ErrInvalidBufferSize)
+	}
+	var hostFlags = flags & (USE_HOST_PTR | COPY_HOST_PTR)
+	if (host == nil) != (hostFlags == 0) || hostFlags == USE_HOST_PTR|COPY_HOST_PTR {
+		return nil, codeError("clCreateBuffer", ErrInvalidHostPtr)
+	}
+	if flags&USE_HOST_PTR != 0 {
+		return &fakeBuffer{backend: ctx.backend, data: unsafe.Slice((*byte)(host), size)}, nil
+	}
+	var data = fakeAlloc(size)
+	if host != nil {
+		copy(data, unsafe.Slice((*byte)(host), size))
+	}
+	return &fakeBuffer{backend: ctx.backend, data: data}, nil
+}
+
+// fakeAlloc allocates zeroed memory aligned for any scalar type.
+// The result is not nil for size 0, so it is told apart from an unset value.
+func fakeAlloc(size int) []byte {
+	var words = make([]uint64, max((size+7)/8, 1))
+	return unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), size)
+}
+
+var (
+	fakeKernelSignature = regexp.MustCompile(`\b(?:__kernel|kernel)\s+(?:__attribute__\s*\(\(.*?\)\)\s*)?void\s+(\w+)\s*\(([^)]*)\)`)
+	fakeComment         = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+)
+
+func (ctx *fakeContext) BuildProgram(sources []string, options string, cache *ProgramCache) (BackendProgram, error) {
+	var program = &fakeProgram{backend: ctx.backend, signatures: make(map[string][]KernelArg)}
+
+	// comments are blanked out, keeping line breaks for the diagnostics
+	var source = fakeComment.ReplaceAllStringFunc(strings.Join(sources, ""), func(comment string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '\n' {
+				return r
+			}
+			return ' '
+		}, comment)
+	})
+	var log []string
+	for _, m := range fakeKernelSignature.FindAllStringSubmatchIndex(source, -1) {
+		var name = source[m[2]:m[3]]
+		var args []KernelArg
+		for _, param := range strings.Split(source[m[4]:m[5]], ",") {
+			if param = strings.TrimSpace(param); param != "" && param != "void" {
+				args = append(args, parseFakeArg(param))
+			}
+		}
+		program.names = append(program.names, name)
+		program.signatures[name] = args
+		if ctx.backend.kernel(name) == nil {
+			var line = strings.Count(source[:m[2]], "\n") + 1
+			var column = m[2] - strings.LastIndex(source[:m[2]], "\n")
+			log = append(log, fmt.Sprintf("<kernel>:%d:%d: warning: kernel %q has no Go implementation, register it with FakeBackend.RegisterKernel",
+				line, column, name))
+		}
+	}
+	program.log = strings.Join(log, "\n")
+	return program, nil
+}
+
+// parseFakeArg parses a kernel parameter declaration like "__global const float* a".
+func parseFakeArg(param string) KernelArg {
+	var arg = KernelArg{AddressQualifier: AddressPrivate, AccessQualifier: AccessNone}
+	var fields = strings.Fields(strings.ReplaceAll(param, "*", " * "))
+	var typeName []string
+	for i, field := range fields {
+		if i == len(fields)-1 && field != "*" {
+			arg.Name = field
+			break
+		}
+		switch field {
+		case "__global", "global":
+			arg.AddressQualifier = AddressGlobal
+		case "__local", "local":
+			arg.AddressQualifier = AddressLocal
+		case "__constant", "constant":
+			arg.AddressQualifier = AddressConstant
+		case "__read_only", "read_only":
+			arg.AccessQualifier = AccessReadOnly
+		case "__write_only", "write_only":
+			arg.AccessQualifier = AccessWriteOnly
+		case "__read_write", "read_write":
+			arg.AccessQualifier = AccessReadWrite
+		case "__private", "private", "const", "restrict", "__restrict", "volatile":
+			// not part of the type name reported by clGetKernelArgInfo
+		default:
+			typeName = append(typeName, field)
+		}
+	}
+	if len(typeName) > 1 && typeName[0] == "unsigned" {
+		typeName = append([]string{"u" + typeName[1]}, typeName[2:]...)
+	}
+	arg.TypeName = strings.ReplaceAll(strings.Join(typeName, " "), " *", "*")
+	return arg
+}
+
+type fakeProgram struct {
+	backend    *FakeBackend
+	names      []string
+	signatures map[string][]KernelArg
+	log        string
+}
+
+func (program *fakeProgram) Release() ErrorCode {
+	return C.CL_SUCCESS
+}
+
+func (program *fakeProgram) BuildLog(device *OpenCLDevice) BuildLog {
+	return BuildLog{Device: device.Name, Log: program.log, Diagnostics: parseBuildLog(program.log)}
+}
+
+func (program *fakeProgram) KernelNames() ([]string, error) {
+	return program.names, nil
+}
+
+func (program *fakeProgram) NewKernel(name string) (BackendKernel, error) {
+	var args, ok = program.signatures[name]
+	if !ok {
+		var err = codeError("clCreateKernel", ErrInvalidKernelName)
+		err.Kernel = name
+		return nil, err
+	}
+	return &fakeKernel{backend: program.backend, name: name, args: args, values: make([]fakeArg, len(args))}, nil
+}
+
+type fakeKernel struct {
+	backend *FakeBackend
+	name    string
+	args    []KernelArg
+	values  []fakeArg
+}
+
+// fakeArg is a kernel argument set with SetArg.
+type fakeArg struct {
+	set    bool
+	buffer *fakeBuffer
+	local  int
+	value  []byte
+}
+
+func (kernel *fakeKernel) Release() ErrorCode {
+	return C.CL_SUCCESS
+}
+
+func (kernel *fakeKernel) Clone() (BackendKernel, error) {
+	return &fakeKernel{backend: kernel.backend, name: kernel.name, args: kernel.args, values: make([]fakeArg, len(kernel.args))}, nil
+}
+
+func (kernel *fakeKernel) NumArgs() (int, error) {
+	return len(kernel.args), nil
+}
+
+func (kernel *fakeKernel) ArgInfo(index int) (KernelArg, error) {
+	return kernel.args[index], nil
+}
+
+func (kernel *fakeKernel) SetArg(index int, param KernelParam) error {
+	if index < 0 || index >= len(kernel.values) {
+		return codeError("clSetKernelArg", ErrInvalidArgIndex)
+	}
+	var value = fakeArg{set: true}
+	switch {
+	case param.buffer != nil:
+		var buffer, ok = param.buffer.buffer.(*fakeBuffer)
+		if !ok {
+			return codeError("clSetKernelArg", ErrInvalidMemObject)
+		}
+		value.buffer = buffer
+	case param.Pointer == nil:
+		value.local = int(param.Size)
+	default:
+		value.value = fakeAlloc(int(param.Size))
+		copy(value.value, unsafe.Slice((*byte)(param.Pointer), param.Size))
+	}
+	kernel.values[index] = value
+	return nil
+}
+
+type fakeBuffer struct {
+	backend *FakeBackend
+	data    []byte // nil once released
+}
+
+func (buffer *fakeBuffer) Release() ErrorCode {
+	buffer.backend.exec.Lock()
+	defer buffer.backend.exec.Unlock()
+	buffer.data = nil
+	return C.CL_SUCCESS
+}
+
+// fakeRange returns the bytes of a buffer for a transfer or mapping.
+func fakeRange(fn string, buffer BackendBuffer, offset int, size int) ([]byte, error) {
+	var b, ok = buffer.(*fakeBuffer)
+	if !ok || b.data == nil {
+		return nil, codeError(fn, ErrInvalidMemObject)
+	}
+	if offset < 0 || size <= 0 || offset+size > len(b.data) {
+		return nil, codeError(fn, ErrInvalidValue)
+	}
+	return b.data[offset : offset+size], nil
+}
+
+type fakeQueue struct {
+	backend   *FakeBackend
+	profiling bool
+}
+
+func (queue *fakeQueue) Release() ErrorCode {
+	return C.CL_SUCCESS
+}
+
+// run executes a command and returns its event if withEvent is true.
+func (queue *fakeQueue) run(withEvent bool, command func() error) (BackendEvent, error) {
+	queue.backend.exec.Lock()
+	defer queue.backend.exec.Unlock()
+
+	var queued = time.Since(queue.backend.epoch)
+	if err := command(); err != nil {
+		return nil, err
+	}
+	if !withEvent {
+		return nil, nil
+	}
+	var event = &fakeEvent{profiling: queue.profiling}
+	event.times = Profile{Queued: queued, Submitted: queued, Started: queued, Ended: time.Since(queue.backend.epoch)}
+	return event, nil
+}
+
+func (queue *fakeQueue) EnqueueKernel(kernel BackendKernel, workDim int, offset, global, local []uint64,
+	waitList []BackendEvent, withEvent bool) (BackendEvent, error) {
+	var fake, ok = kernel.(*fakeKernel)
+	if !ok {
+		return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidKernel)
+	}
+	if workDim < 1 || workDim > 3 {
+		return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidWorkDimension)
+	}
+	if len(global) != workDim || (offset != nil && len(offset) != workDim) {
+		return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidGlobalWorkSize)
+	}
+	if local != nil && len(local) != workDim {
+		return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidWorkGroupSize)
+	}
+
+	var item = WorkItem{Dim: workDim, GlobalSize: [3]int{1, 1, 1}, LocalSize: [3]int{1, 1, 1}, NumGroups: [3]int{1, 1, 1}}
+	var globalOffset [3]int
+	for d := 0; d < workDim; d++ {
+		item.GlobalSize[d] = int(global[d])
+		if item.GlobalSize[d] == 0 {
+			return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidGlobalWorkSize)
+		}
+		// without local sizes, the NDRange is a single work-group
+		item.LocalSize[d] = item.GlobalSize[d]
+		if local != nil {
+			item.LocalSize[d] = int(local[d])
+			if item.LocalSize[d] == 0 || item.GlobalSize[d]%item.LocalSize[d] != 0 {
+				return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidWorkGroupSize)
+			}
+		}
+		item.NumGroups[d] = item.GlobalSize[d] / item.LocalSize[d]
+		if offset != nil {
+			globalOffset[d] = int(offset[d])
+		}
+	}
+	for _, value := range fake.values {
+		if !value.set {
+			return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidKernelArgs)
+		}
+		if value.buffer != nil && value.buffer.data == nil {
+			return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidMemObject)
+		}
+	}
+	var fn = fake.backend.kernel(fake.name)
+	if fn == nil {
+		return nil, codeError("clEnqueueNDRangeKernel", ErrInvalidKernel)
+	}
+
+	// the arguments are captured at enqueue time, like clEnqueueNDRangeKernel does
+	var args = make(FakeArgs, len(fake.values))
+	var values = append([]fakeArg(nil), fake.values...)
+	return queue.run(withEvent, func() error {
+		for i, value := range values {
+			switch {
+			case value.buffer != nil:
+				args[i] = value.buffer.data
+			case value.value != nil:
+				args[i] = value.value
+			}
+		}
+		for g := 0; g < item.NumGroups[0]*item.NumGroups[1]*item.NumGroups[2]; g++ {
+			item.GroupID = [3]int{g % item.NumGroups[0], g / item.NumGroups[0] % item.NumGroups[1], g / (item.NumGroups[0] * item.NumGroups[1])}
+			for i, value := range values {
+				if value.buffer == nil && value.value == nil {
+					args[i] = fakeAlloc(value.local)
+				}
+			}
+			for l := 0; l < item.LocalSize[0]*item.LocalSize[1]*item.LocalSize[2]; l++ {
+				item.LocalID = [3]int{l % item.LocalSize[0], l / item.LocalSize[0] % item.LocalSize[1], l / (item.LocalSize[0] * item.LocalSize[1])}
+				for d := 0; d < 3; d++ {
+					item.GlobalID[d] = globalOffset[d] + item.GroupID[d]*item.LocalSize[d] + item.LocalID[d]
+				}
+				fn(item, args)
+			}
+		}
+		return nil
+	})
+}
+
+func (queue *fakeQueue) EnqueueRead(buffer BackendBuffer, blocking bool, offset int, size int, target unsafe.Pointer,
+	waitList []BackendEvent, withEvent bool) (BackendEvent, error) {
+	return queue.run(withEvent, func() error {
+		data, err := fakeRange("clEnqueueReadBuffer", buffer, offset, size)
+		if err != nil {
+			return err
+		}
+		copy(unsafe.Slice((*byte)(target), size), data)
+		return nil
+	})
+}
+
+func (queue *fakeQueue) EnqueueWrite(buffer BackendBuffer, blocking bool, offset int, size int, source unsafe.Pointer,
+	waitList []BackendEvent, withEvent bool) (BackendEvent, error) {
+	return queue.run(withEvent, func() error {
+		data, err := fakeRange("clEnqueueWriteBuffer", buffer, offset, size)
+		if err != nil {
+			return err
+		}
+		copy(data, unsafe.Slice((*byte)(source), size))
+		return nil
+	})
+}
+
+func (queue *fakeQueue) MapBuffer(buffer BackendBuffer, flags MapFlags, offset int, size int) (unsafe.Pointer, error) {
+	queue.backend.exec.Lock()
+	defer queue.backend.exec.Unlock()
+	data, err := fakeRange("clEnqueueMapBuffer", buffer, offset, size)
+	if err != nil {
+		return nil, err
+	}
+	return unsafe.Pointer(&data[0]), nil
+}
+
+func (queue *fakeQueue) UnmapBuffer(buffer BackendBuffer, mapped unsafe.Pointer) error {
+	return nil
+}
+
+func (queue *fakeQueue) Flush() error {
+	return nil
+}
+
+func (queue *fakeQueue) Finish() error {
+	return nil
+}
+
+// fakeEvent is the event of a command that completed when it was enqueued.
+type fakeEvent struct {
+	profiling bool
+	times     Profile
+}
+
+func (e *fakeEvent) Release() ErrorCode {
+	return C.CL_SUCCESS
+}
+
+func (e *fakeEvent) Wait() error {
+	return nil
+}
+
+func (e *fakeEvent) Status() (EventStatus, error) {
+	return Complete, nil
+}
+
+func (e *fakeEvent) Notify(done func(status EventStatus)) error {
+	done(Complete)
+	return nil
+}
+
+func (e *fakeEvent) Profile() (Profile, error) {
+	if !e.profiling {
+		return Profile{}, codeError("clGetEventProfilingInfo", ErrProfilingInfoNotAvailable)
+	}
+	return e.times, nil
+}
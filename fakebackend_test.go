@@ -0,0 +1,273 @@
+package opencl
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const fakeTestSource = `
+// squares the input, scaled by factor
+__kernel void square(__global const int* in, __global int* out, const int factor)
+{
+	int num = get_global_id(0);
+	out[num] = in[num] * in[num] * factor;
+}
+
+/* sums each work-group in local memory */
+__kernel __attribute__((reqd_work_group_size(4, 1, 1)))
+void groupsum(__global const float* in, __global float* out, __local float* scratch)
+{
+	scratch[get_local_id(0)] = in[get_global_id(0)];
+	barrier(CLK_LOCAL_MEM_FENCE);
+	if (get_local_id(0) == 0) {
+		float sum = 0;
+		for (int i = 0; i < get_local_size(0); i++) sum += scratch[i];
+		out[get_group_id(0)] = sum;
+	}
+}
+
+__kernel void unregistered(unsigned int n) {}
+`
+
+func newFakeTestRunner(t *testing.T, opts ...RunnerOption) *OpenCLRunner {
+	return newFakeTestBackendRunner(t, newFakeTestBackend(), opts...)
+}
+
+func newFakeTestBackend() *FakeBackend {
+	var backend = NewFakeBackend()
+	backend.RegisterKernel("square", func(item WorkItem, args FakeArgs) {
+		var in, out = FakeSlice[int32](args[0]), FakeSlice[int32](args[1])
+		var num = item.GlobalID[0]
+		out[num] = in[num] * in[num] * FakeValue[int32](args[2])
+	})
+	// work-items run in order, so the last one of a group sees the scratch values of the others
+	backend.RegisterKernel("groupsum", func(item WorkItem, args FakeArgs) {
+		var in, out, scratch = FakeSlice[float32](args[0]), FakeSlice[float32](args[1]), FakeSlice[float32](args[2])
+		scratch[item.LocalID[0]] = in[item.GlobalID[0]]
+		if item.LocalID[0] == item.LocalSize[0]-1 {
+			var sum float32
+			for _, v := range scratch {
+				sum += v
+			}
+			out[item.GroupID[0]] = sum
+		}
+	})
+	return backend
+}
+
+// newFakeTestBackendRunner returns a runner of the backend with the kernels of fakeTestSource, freed by the test cleanup.
+func newFakeTestBackendRunner(t *testing.T, backend *FakeBackend, opts ...RunnerOption) *OpenCLRunner {
+	runner, err := backend.InitRunner(opts...)
+	if err != nil {
+		t.Fatal("InitRunner err:", err)
+	}
+	t.Cleanup(func() { runner.Free() })
+	if err = runner.CompileAllKernels([]string{fakeTestSource}, ""); err != nil {
+		t.Fatal("CompileAllKernels err:", err)
+	}
+	return runner
+}
+
+func TestFakeBackend(t *testing.T) {
+	var runner = newFakeTestRunner(t)
+
+	input := []int32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	inputBuf, err := CreateBuffer(runner, READ_ONLY|COPY_HOST_PTR, input)
+	if err != nil {
+		t.Fatal("CreateBuffer err:", err)
+	}
+	outputBuf, err := runner.CreateEmptyBuffer(WRITE_ONLY, 4*len(input))
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	var factor int32 = 2
+	err = runner.RunKernel("square", 1, nil, []uint64{uint64(len(input))}, nil, []KernelParam{
+		BufferParam(inputBuf), BufferParam(outputBuf), Param(&factor),
+	}, true)
+	if err != nil {
+		t.Fatal("RunKernel err:", err)
+	}
+
+	result := make([]int32, len(input))
+	if err = ReadBuffer(runner, 0, outputBuf, result); err != nil {
+		t.Fatal("ReadBuffer err:", err)
+	}
+	for i, v := range input {
+		if result[i] != v*v*factor {
+			t.Fatalf("result %v, want squares of %v times %d", result, input, factor)
+		}
+	}
+}
+
+func TestFakeBackendLocalMemory(t *testing.T) {
+	var runner = newFakeTestRunner(t)
+
+	input := []float32{1, 2, 3, 4, 5, 6, 7, 8}
+	inputBuf, err := CreateBuffer(runner, READ_ONLY|COPY_HOST_PTR, input)
+	if err != nil {
+		t.Fatal("CreateBuffer err:", err)
+	}
+	outputBuf, err := runner.CreateEmptyBuffer(WRITE_ONLY, 4*2)
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	err = runner.RunKernel("groupsum", 1, nil, []uint64{8}, []uint64{4}, []KernelParam{
+		BufferParam(inputBuf), BufferParam(outputBuf), LocalParam(4 * 4),
+	}, true)
+	if err != nil {
+		t.Fatal("RunKernel err:", err)
+	}
+
+	result := make([]float32, 2)
+	if err = ReadBuffer(runner, 0, outputBuf, result); err != nil {
+		t.Fatal("ReadBuffer err:", err)
+	}
+	if want := []float32{10, 26}; !slices.Equal(result, want) {
+		t.Errorf("group sums %v, want %v", result, want)
+	}
+}
+
+func TestFakeBackendZeroSizeArgs(t *testing.T) {
+	var runner = newFakeTestRunner(t)
+	var kernel = runner.Kernels["unregistered"].kernel.(*fakeKernel)
+
+	// Kernel.Validate accepts zero-sized values of types it does not know
+	var empty struct{}
+	if err := kernel.SetArg(0, Param(&empty)); err != nil {
+		t.Fatal("setArg of a zero-sized value err:", err)
+	}
+	if value := kernel.values[0]; value.value == nil || len(value.value) != 0 {
+		t.Errorf("zero-sized value %+v, want an empty value", value)
+	}
+	if err := kernel.SetArg(0, LocalParam(0)); err != nil {
+		t.Fatal("setArg of no local memory err:", err)
+	}
+	if value := kernel.values[0]; value.value != nil || value.local != 0 {
+		t.Errorf("no local memory %+v, want a local argument", value)
+	}
+	if data := fakeAlloc(0); data == nil || len(data) != 0 {
+		t.Errorf("fakeAlloc(0) = %v, want an empty slice", data)
+	}
+}
+
+func TestFakeBackendErrors(t *testing.T) {
+	var runner = newFakeTestRunner(t)
+	buffer, err := runner.CreateEmptyBuffer(READ_WRITE, 16)
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	var n uint32 = 4
+	var factor int32 = 1
+
+	var argErr *KernelArgError
+	err = runner.RunKernel("square", 1, nil, []uint64{4}, nil, []KernelParam{BufferParam(buffer), BufferParam(buffer), BufferParam(buffer)}, true)
+	if !errors.As(err, &argErr) || argErr.Index != 2 {
+		t.Errorf("RunKernel with a buffer for a scalar = %v, want a KernelArgError for argument 2", err)
+	}
+	err = runner.RunKernel("square", 1, nil, []uint64{4}, []uint64{3}, []KernelParam{BufferParam(buffer), BufferParam(buffer), Param(&factor)}, true)
+	if !errors.Is(err, ErrInvalidWorkGroupSize) {
+		t.Errorf("RunKernel with an indivisible local size = %v, want %v", err, ErrInvalidWorkGroupSize)
+	}
+	err = runner.RunKernel("unregistered", 1, nil, []uint64{4}, nil, []KernelParam{Param(&n)}, true)
+	if !errors.Is(err, ErrInvalidKernel) {
+		t.Errorf("RunKernel of an unregistered kernel = %v, want %v", err, ErrInvalidKernel)
+	}
+	if err = ReadBuffer(runner, 8, buffer, make([]int32, 4)); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ReadBuffer out of bounds = %v, want %v", err, ErrInvalidValue)
+	}
+	if _, err = runner.CreateEmptyBuffer(READ_WRITE|COPY_HOST_PTR, 16); !errors.Is(err, ErrInvalidHostPtr) {
+		t.Errorf("CreateEmptyBuffer with COPY_HOST_PTR = %v, want %v", err, ErrInvalidHostPtr)
+	}
+	if _, err = runner.BuildProgram([]string{fakeTestSource}, []string{"missing"}, ""); !errors.Is(err, ErrInvalidKernelName) {
+		t.Errorf("BuildProgram of a missing kernel = %v, want %v", err, ErrInvalidKernelName)
+	}
+}
+
+func TestFakeBackendProgram(t *testing.T) {
+	var runner = newFakeTestRunner(t)
+
+	kernel, err := runner.Kernel("groupsum")
+	if err != nil {
+		t.Fatal("Kernel err:", err)
+	}
+	var args []string
+	for _, arg := range kernel.Args {
+		args = append(args, arg.String())
+	}
+	if want := []string{"__global float* in", "__global float* out", "__local float* scratch"}; !slices.Equal(args, want) {
+		t.Errorf("groupsum args %q, want %q", args, want)
+	}
+	if kernel, err = runner.Kernel("unregistered"); err != nil || len(kernel.Args) != 1 || kernel.Args[0].TypeName != "uint" {
+		t.Errorf("unregistered args %v, err %v, want a uint", kernel.Args, err)
+	}
+
+	var logs = runner.Programs[0].Logs
+	if len(logs) != 1 || len(logs[0].Diagnostics) != 1 {
+		t.Fatalf("build logs %v, want a warning for the unregistered kernel", logs)
+	}
+	if d := logs[0].Diagnostics[0]; d.Line != 22 || d.Severity != "warning" {
+		t.Errorf("diagnostic %+v, want a warning on line 22", d)
+	}
+	// CompileAllKernels adds every kernel of the source
+	if names := runner.Programs[0].kernelNameList(); !slices.Equal(names, []string{"groupsum", "square", "unregistered"}) {
+		t.Errorf("kernels %q, want every kernel of the source", names)
+	}
+	const other = `__kernel void square(__global int* v) { }
+__kernel void other(__global int* v) { }`
+	if err = runner.CompileAllKernels([]string{other}, ""); err == nil || !strings.Contains(err.Error(), `kernel "square" is already defined`) {
+		t.Errorf("CompileAllKernels of a second square = %v", err)
+	}
+	program, err := runner.BuildProgram([]string{other}, nil, "")
+	if err != nil {
+		t.Fatal("BuildProgram err:", err)
+	}
+	defer program.Release()
+	if len(program.Kernels) != 0 {
+		t.Errorf("BuildProgram without kernel names created the kernels %v", program.Kernels)
+	}
+	if names, err := program.KernelNames(); !slices.Equal(names, []string{"square", "other"}) {
+		t.Errorf("KernelNames = %q, %v", names, err)
+	}
+	if err = runner.CompileKernels([]string{other}, []string{"other"}, ""); err != nil {
+		t.Errorf("CompileKernels of only the other kernel err: %v", err)
+	}
+}
+
+func TestFakeBackendProfiling(t *testing.T) {
+	var runner = newFakeTestRunner(t, WithProfiling())
+	buffer, err := runner.CreateEmptyBuffer(READ_WRITE, 16)
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	var factor int32 = 1
+	event, err := runner.EnqueueKernel("square", 1, nil, []uint64{4}, nil, []KernelParam{BufferParam(buffer), BufferParam(buffer), Param(&factor)}, nil)
+	if err != nil {
+		t.Fatal("EnqueueKernel err:", err)
+	}
+	defer event.Release()
+	if status, err := event.Status(); err != nil || status != Complete {
+		t.Errorf("event status %v, err %v, want %v", status, err, Complete)
+	}
+	if profile, err := event.Profile(); err != nil || profile.Ended < profile.Started {
+		t.Errorf("profile %+v, err %v", profile, err)
+	}
+	if report := runner.ProfilingReport(); len(report) != 1 || report[0].Name != "square" || report[0].Count != 1 {
+		t.Errorf("profiling report %+v, want one run of square", report)
+	}
+
+	// the events of completed commands are not kept until the next report
+	for i := 0; i < 2000; i++ {
+		err = runner.RunKernel("square", 1, nil, []uint64{4}, nil, []KernelParam{BufferParam(buffer), BufferParam(buffer), Param(&factor)}, false)
+		if err != nil {
+			t.Fatal("RunKernel err:", err)
+		}
+	}
+	if pending := len(runner.pendingEvents); pending > minPendingLimit {
+		t.Errorf("%d pending events after 2000 completed commands", pending)
+	}
+	if report := runner.ProfilingReport(); len(report) != 1 || report[0].Count != 2001 {
+		t.Errorf("profiling report %+v, want 2001 runs of square", report)
+	}
+}
@@ -0,0 +1,110 @@
+package opencl
+
+import (
+	"context"
+	"time"
+)
+
+// Operation describes a runner call passed to a Hook. Only the fields relevant to Name are set.
+type Operation struct {
+	// Name is "CompileKernels", "CompileAllKernels", "CreateBuffer", "WriteBuffer", "EnqueueWriteBuffer", "ReadBuffer",
+	// "EnqueueReadBuffer", "RunKernel", "EnqueueKernel" or "Free". The Ctx variants of RunKernel, ReadBuffer
+	// and WriteBuffer have the name of the call they wait like, and pass their context to the hooks.
+	Name   string
+	Runner *OpenCLRunner
+
+	Kernel     string   // the kernel of RunKernel and EnqueueKernel
+	Kernels    []string // the kernels requested from CompileKernels
+	GlobalSize []uint64
+	LocalSize  []uint64
+	Queue      int // the index of the queue in OpenCLRunner.Queues
+	Bytes      int // the size of the buffer created, or the number of bytes transferred
+
+	// Set before After is called.
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// Hook observes the operations of a runner, e.g. to create tracing spans or update metrics.
+//
+// Before is called before the operation and returns the context passed to After, so it can carry a span.
+// After is called once the operation has returned, with Duration and Err set. Hooks are called
+// in the goroutine making the call, in the order they were given to WithHooks, and After in reverse order.
+// The operation must not be retained after After returns.
+type Hook interface {
+	Before(ctx context.Context, op *Operation) context.Context
+	After(ctx context.Context, op *Operation)
+}
+
+// HookFuncs is a Hook made of functions; either may be nil.
+type HookFuncs struct {
+	BeforeFunc func(ctx context.Context, op *Operation) context.Context
+	AfterFunc  func(ctx context.Context, op *Operation)
+}
+
+func (h HookFuncs) Before(ctx context.Context, op *Operation) context.Context {
+	if h.BeforeFunc == nil {
+		return ctx
+	}
+	return h.BeforeFunc(ctx, op)
+}
+
+func (h HookFuncs) After(ctx context.Context, op *Operation) {
+	if h.AfterFunc != nil {
+		h.AfterFunc(ctx, op)
+	}
+}
+
+// WithHooks calls the hooks before and after the operations of the runner.
+// Operations without a context.Context parameter pass context.Background() to the hooks.
+func WithHooks(hooks ...Hook) RunnerOption {
+	return func(config *runnerConfig) {
+		config.hooks = append(config.hooks, hooks...)
+	}
+}
+
+// hookCall is an operation whose Before hooks have been called.
+type hookCall struct {
+	hooks []Hook
+	ctxs  []context.Context // the context returned by the Before of each hook
+	op    Operation
+}
+
+// beforeHooks calls the Before hooks of the runner for op.
+// The returned call, nil if the runner has no hooks, must be completed with after.
+func (runner *OpenCLRunner) beforeHooks(ctx context.Context, op Operation) *hookCall {
+	if len(runner.hooks) == 0 {
+		return nil
+	}
+	op.Runner = runner
+	op.Start = time.Now()
+	var call = &hookCall{hooks: runner.hooks, ctxs: make([]context.Context, len(runner.hooks)), op: op}
+	for i, hook := range call.hooks {
+		if next := hook.Before(ctx, &call.op); next != nil {
+			ctx = next
+		}
+		call.ctxs[i] = ctx
+	}
+	return call
+}
+
+// after calls the After hooks with the outcome of the operation.
+func (call *hookCall) after(err error) {
+	if call == nil {
+		return
+	}
+	call.op.Duration = time.Since(call.op.Start)
+	call.op.Err = err
+	for i := len(call.hooks) - 1; i >= 0; i-- {
+		call.hooks[i].After(call.ctxs[i], &call.op)
+	}
+}
+
+// MemoryInUse returns the total size of the buffers created by the runner that are not released yet.
+func (runner *OpenCLRunner) MemoryInUse() int64 {
+	if runner.tracker == nil {
+		return 0
+	}
+	return runner.tracker.memory.Load()
+}
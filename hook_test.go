@@ -0,0 +1,96 @@
+package opencl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+type spanKey struct{}
+
+// recordingHook records the operations of a runner and passes a span name from Before to After.
+type recordingHook struct {
+	calls []string
+	ops   []Operation
+}
+
+func (h *recordingHook) Before(ctx context.Context, op *Operation) context.Context {
+	h.calls = append(h.calls, "before "+op.Name)
+	return context.WithValue(ctx, spanKey{}, op.Name)
+}
+
+func (h *recordingHook) After(ctx context.Context, op *Operation) {
+	h.calls = append(h.calls, fmt.Sprintf("after %s in %v", op.Name, ctx.Value(spanKey{})))
+	h.ops = append(h.ops, *op)
+}
+
+func TestHooks(t *testing.T) {
+	var hook recordingHook
+	var order []string
+	var outer = HookFuncs{
+		BeforeFunc: func(ctx context.Context, op *Operation) context.Context {
+			order = append(order, "outer before")
+			return ctx
+		},
+		AfterFunc: func(ctx context.Context, op *Operation) { order = append(order, "outer after") },
+	}
+	var inner = HookFuncs{AfterFunc: func(ctx context.Context, op *Operation) { order = append(order, "inner after") }}
+	var runner = newFakeTestRunner(t, WithHooks(outer, &hook, inner))
+
+	buffer, err := runner.CreateEmptyBuffer(READ_WRITE, 32)
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	if runner.MemoryInUse() != 32 {
+		t.Errorf("MemoryInUse = %d, want 32", runner.MemoryInUse())
+	}
+	if err = WriteBuffer(runner, 0, buffer, make([]int32, 8), true); err != nil {
+		t.Fatal("WriteBuffer err:", err)
+	}
+	var factor int32 = 1
+	err = runner.RunKernel("square", 1, nil, []uint64{8}, nil, []KernelParam{BufferParam(buffer), BufferParam(buffer), Param(&factor)}, true)
+	if err != nil {
+		t.Fatal("RunKernel err:", err)
+	}
+	if err = runner.RunKernel("missing", 1, nil, []uint64{8}, nil, nil, true); !errors.Is(err, ErrInvalidKernelName) {
+		t.Fatalf("RunKernel of a missing kernel = %v", err)
+	}
+	if err = ReadBuffer(runner, 0, buffer, make([]int32, 8)); err != nil {
+		t.Fatal("ReadBuffer err:", err)
+	}
+	runner.ReleaseBuffer(buffer)
+	if runner.MemoryInUse() != 0 {
+		t.Errorf("MemoryInUse after ReleaseBuffer = %d, want 0", runner.MemoryInUse())
+	}
+	if err = runner.Free(); err != nil {
+		t.Fatal("Free err:", err)
+	}
+
+	var names []string
+	for _, op := range hook.ops {
+		names = append(names, op.Name)
+		if op.Runner != runner || op.Start.IsZero() {
+			t.Errorf("%s: runner %p, start %v", op.Name, op.Runner, op.Start)
+		}
+	}
+	if want := []string{"CompileAllKernels", "CreateBuffer", "WriteBuffer", "RunKernel", "RunKernel", "ReadBuffer", "Free"}; !slices.Equal(names, want) {
+		t.Fatalf("operations %q, want %q", names, want)
+	}
+	if op := hook.ops[1]; op.Bytes != 32 {
+		t.Errorf("CreateBuffer bytes %d, want 32", op.Bytes)
+	}
+	if op := hook.ops[3]; op.Kernel != "square" || !slices.Equal(op.GlobalSize, []uint64{8}) || op.Err != nil {
+		t.Errorf("RunKernel %+v", op)
+	}
+	if op := hook.ops[4]; !errors.Is(op.Err, ErrInvalidKernelName) {
+		t.Errorf("RunKernel of a missing kernel has error %v", op.Err)
+	}
+	if want := "after ReadBuffer in ReadBuffer"; hook.calls[11] != want {
+		t.Errorf("call %q, want %q with the context returned by Before", hook.calls[11], want)
+	}
+	if want := []string{"outer before", "inner after", "outer after"}; !slices.Equal(order[:3], want) {
+		t.Errorf("hook order %q, want %q", order[:3], want)
+	}
+}
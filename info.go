@@ -4,228 +4,438 @@ package opencl
 import "C"
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"unsafe"
 )
 
-func getOneDevie(platform_id C.cl_platform_id, device_id C.cl_device_id) (*OpenCLDevice, error) {
-	var device = OpenCLDevice{Platform_id: platform_id, Device_id: device_id}
-
-	// max_clock_frequency
-	var err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_MAX_CLOCK_FREQUENCY, C.sizeof_cl_uint,
-		unsafe.Pointer(&device.Max_clock_frequency), nil)
+// getDeviceInfoString queries a string parameter of the device.
+func getDeviceInfoString(device_id C.cl_device_id, param C.cl_device_info) (string, error) {
+	var infoSize C.size_t
+	var err = C.clGetDeviceInfo(device_id, param, 0, nil, &infoSize)
 	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+		return "", clError("clGetDeviceInfo", err)
 	}
-
-	// max_mem_alloc_size
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_MAX_MEM_ALLOC_SIZE, C.sizeof_cl_ulong,
-		unsafe.Pointer(&device.Max_mem_alloc_size), nil)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+	if infoSize <= 1 {
+		return "", nil
 	}
-
-	// global_mem_size
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_GLOBAL_MEM_SIZE, C.sizeof_cl_ulong,
-		unsafe.Pointer(&device.Global_mem_size), nil)
+	var info = make([]byte, infoSize, infoSize)
+	err = C.clGetDeviceInfo(device_id, param, infoSize, unsafe.Pointer(&info[0]), nil)
 	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+		return "", clError("clGetDeviceInfo", err)
 	}
+	return string(info[:len(info)-1]), nil
+}
 
-	// max_compute_units
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_MAX_COMPUTE_UNITS, C.sizeof_cl_uint,
-		unsafe.Pointer(&device.Max_compute_units), nil)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
-	}
+// CL_DEVICE_HALF_FP_CONFIG is defined in cl_ext.h as part of cl_khr_fp16.
+const clDeviceHalfFPConfig = 0x1033
 
-	// max_work_group_size
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_MAX_WORK_GROUP_SIZE, C.sizeof_size_t,
-		unsafe.Pointer(&device.Max_work_group_size), nil)
+// getDeviceInfo queries a fixed size parameter of the device into value.
+func getDeviceInfo[T any](device_id C.cl_device_id, param C.cl_device_info, value *T) error {
+	var err = C.clGetDeviceInfo(device_id, param, C.size_t(unsafe.Sizeof(*value)), unsafe.Pointer(value), nil)
 	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+		return clError("clGetDeviceInfo", err)
 	}
+	return nil
+}
 
-	// device_type
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_TYPE, C.sizeof_cl_device_type,
-		unsafe.Pointer(&device.Device_type), nil)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
-	}
+// deviceQuery queries the parameters of a device and records the failed ones by field name.
+type deviceQuery struct {
+	device_id C.cl_device_id
+	errors    map[string]error
+	fields    []string
+}
 
-	// Max_work_item_dimensions
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_MAX_WORK_ITEM_DIMENSIONS, C.sizeof_cl_uint,
-		unsafe.Pointer(&device.Max_work_item_dimensions), nil)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+func (query *deviceQuery) record(field string, err error) {
+	if err == nil {
+		return
+	}
+	if query.errors == nil {
+		query.errors = make(map[string]error)
 	}
+	query.errors[field] = err
+	query.fields = append(query.fields, field)
+}
 
-	// Max_work_item_sizes
-	device.Max_work_item_sizes = make([]C.size_t, device.Max_work_item_dimensions, device.Max_work_item_dimensions)
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_MAX_WORK_ITEM_SIZES, C.sizeof_size_t*C.size_t(device.Max_work_item_dimensions),
-		unsafe.Pointer(&device.Max_work_item_sizes[0]), nil)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+// err joins the recorded errors in query order.
+func (query *deviceQuery) err() error {
+	var errs = make([]error, len(query.fields))
+	for i, field := range query.fields {
+		errs[i] = fmt.Errorf("%s: %w", field, query.errors[field])
 	}
+	return errors.Join(errs...)
+}
+
+func (query *deviceQuery) uint(field string, param C.cl_device_info) uint32 {
+	var value C.cl_uint
+	query.record(field, getDeviceInfo(query.device_id, param, &value))
+	return uint32(value)
+}
 
+func (query *deviceQuery) ulong(field string, param C.cl_device_info) uint64 {
+	var value C.cl_ulong
+	query.record(field, getDeviceInfo(query.device_id, param, &value))
+	return uint64(value)
+}
+
+func (query *deviceQuery) size(field string, param C.cl_device_info) int {
+	var value C.size_t
+	query.record(field, getDeviceInfo(query.device_id, param, &value))
+	return int(value)
+}
+
+func (query *deviceQuery) bool(field string, param C.cl_device_info) bool {
+	var value C.cl_bool
+	query.record(field, getDeviceInfo(query.device_id, param, &value))
+	return value != C.CL_FALSE
+}
+
+func (query *deviceQuery) string(field string, param C.cl_device_info) string {
+	value, err := getDeviceInfoString(query.device_id, param)
+	query.record(field, err)
+	return strings.Trim(value, " ")
+}
+
+func (query *deviceQuery) bytes(field string, param C.cl_device_info) []byte {
 	var infoSize C.size_t
-	// name
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_NAME, 0, nil, &infoSize)
+	var err = C.clGetDeviceInfo(query.device_id, param, 0, nil, &infoSize)
 	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+		query.record(field, clError("clGetDeviceInfo", err))
+		return nil
+	}
+	if infoSize == 0 {
+		return nil
 	}
 	var info = make([]byte, infoSize, infoSize)
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_NAME, infoSize, unsafe.Pointer(&info[0]), nil)
+	err = C.clGetDeviceInfo(query.device_id, param, infoSize, unsafe.Pointer(&info[0]), nil)
 	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+		query.record(field, clError("clGetDeviceInfo", err))
+		return nil
 	}
-	device.Name = string(info[:len(info)-1])
+	return info
+}
 
-	// profile
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_PROFILE, 0, nil, &infoSize)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+// vectorWidths queries the widths of params in the order of VectorWidths,
+// recording each one as a field like "Preferred_vector_width.Half".
+func (query *deviceQuery) vectorWidths(field string, params [7]C.cl_device_info) VectorWidths {
+	return VectorWidths{
+		Char:   query.uint(field+".Char", params[0]),
+		Short:  query.uint(field+".Short", params[1]),
+		Int:    query.uint(field+".Int", params[2]),
+		Long:   query.uint(field+".Long", params[3]),
+		Float:  query.uint(field+".Float", params[4]),
+		Double: query.uint(field+".Double", params[5]),
+		Half:   query.uint(field+".Half", params[6]),
 	}
-	info = make([]byte, infoSize, infoSize)
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_PROFILE, infoSize, unsafe.Pointer(&info[0]), nil)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
-	}
-	device.Profile = string(info[:len(info)-1])
+}
 
-	// version
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_VERSION, 0, nil, &infoSize)
+func (query *deviceQuery) partitionProperties(field string) []PartitionProperty {
+	var infoSize C.size_t
+	var err = C.clGetDeviceInfo(query.device_id, C.CL_DEVICE_PARTITION_PROPERTIES, 0, nil, &infoSize)
 	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+		query.record(field, clError("clGetDeviceInfo", err))
+		return nil
+	}
+	var count = int(infoSize) / C.sizeof_cl_device_partition_property
+	if count == 0 {
+		return nil
 	}
-	info = make([]byte, infoSize, infoSize)
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_VERSION, infoSize, unsafe.Pointer(&info[0]), nil)
+	var values = make([]C.cl_device_partition_property, count, count)
+	err = C.clGetDeviceInfo(query.device_id, C.CL_DEVICE_PARTITION_PROPERTIES, infoSize, unsafe.Pointer(&values[0]), nil)
 	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+		query.record(field, clError("clGetDeviceInfo", err))
+		return nil
 	}
-	device.Version = strings.Trim(string(info[:len(info)-1]), " ")
+	var properties []PartitionProperty
+	for _, value := range values {
+		if value != 0 {
+			properties = append(properties, PartitionProperty(value))
+		}
+	}
+	return properties
+}
 
-	// vendor
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_VENDOR, 0, nil, &infoSize)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+// getOneDevie queries the parameters of a device.
+// A failed query does not abort the others: it is recorded in Info_errors and the field keeps its zero value.
+func getOneDevie(platform_id C.cl_platform_id, device_id C.cl_device_id) (*OpenCLDevice, error) {
+	var device = OpenCLDevice{Platform_id: PlatformID{platform_id}, Device_id: DeviceID{device_id}}
+	var query = deviceQuery{device_id: device_id}
+
+	device.Max_clock_frequency = query.uint("Max_clock_frequency", C.CL_DEVICE_MAX_CLOCK_FREQUENCY)
+	device.Max_mem_alloc_size = query.ulong("Max_mem_alloc_size", C.CL_DEVICE_MAX_MEM_ALLOC_SIZE)
+	device.Global_mem_size = query.ulong("Global_mem_size", C.CL_DEVICE_GLOBAL_MEM_SIZE)
+	device.Max_compute_units = query.uint("Max_compute_units", C.CL_DEVICE_MAX_COMPUTE_UNITS)
+	device.Max_work_group_size = query.size("Max_work_group_size", C.CL_DEVICE_MAX_WORK_GROUP_SIZE)
+	device.Device_type = DeviceType(query.ulong("Device_type", C.CL_DEVICE_TYPE))
+	device.Max_work_item_dimensions = query.uint("Max_work_item_dimensions", C.CL_DEVICE_MAX_WORK_ITEM_DIMENSIONS)
+
+	if device.Max_work_item_dimensions > 0 {
+		var sizes = make([]C.size_t, device.Max_work_item_dimensions, device.Max_work_item_dimensions)
+		var err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_MAX_WORK_ITEM_SIZES, C.sizeof_size_t*C.size_t(len(sizes)),
+			unsafe.Pointer(&sizes[0]), nil)
+		if err != C.CL_SUCCESS {
+			query.record("Max_work_item_sizes", clError("clGetDeviceInfo", err))
+		} else {
+			device.Max_work_item_sizes = make([]int, len(sizes))
+			for i, size := range sizes {
+				device.Max_work_item_sizes[i] = int(size)
+			}
+		}
 	}
-	info = make([]byte, infoSize, infoSize)
-	err = C.clGetDeviceInfo(device_id, C.CL_DEVICE_VENDOR, infoSize, unsafe.Pointer(&info[0]), nil)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+
+	device.Name = query.string("Name", C.CL_DEVICE_NAME)
+	device.Profile = query.string("Profile", C.CL_DEVICE_PROFILE)
+	device.Version = query.string("Version", C.CL_DEVICE_VERSION)
+	device.Vendor = query.string("Vendor", C.CL_DEVICE_VENDOR)
+	device.Driver_version = query.string("Driver_version", C.CL_DRIVER_VERSION)
+
+	// memory
+	device.Local_mem_size = query.ulong("Local_mem_size", C.CL_DEVICE_LOCAL_MEM_SIZE)
+	device.Local_mem_type = LocalMemType(query.uint("Local_mem_type", C.CL_DEVICE_LOCAL_MEM_TYPE))
+	device.Max_constant_buffer_size = query.ulong("Max_constant_buffer_size", C.CL_DEVICE_MAX_CONSTANT_BUFFER_SIZE)
+	device.Max_constant_args = query.uint("Max_constant_args", C.CL_DEVICE_MAX_CONSTANT_ARGS)
+	device.Global_mem_cache_type = CacheType(query.uint("Global_mem_cache_type", C.CL_DEVICE_GLOBAL_MEM_CACHE_TYPE))
+	device.Global_mem_cache_size = query.ulong("Global_mem_cache_size", C.CL_DEVICE_GLOBAL_MEM_CACHE_SIZE)
+	device.Global_mem_cacheline_size = query.uint("Global_mem_cacheline_size", C.CL_DEVICE_GLOBAL_MEM_CACHELINE_SIZE)
+	device.Mem_base_addr_align = query.uint("Mem_base_addr_align", C.CL_DEVICE_MEM_BASE_ADDR_ALIGN)
+	device.Max_parameter_size = query.size("Max_parameter_size", C.CL_DEVICE_MAX_PARAMETER_SIZE)
+
+	// images
+	device.Image_support = query.bool("Image_support", C.CL_DEVICE_IMAGE_SUPPORT)
+	device.Max_read_image_args = query.uint("Max_read_image_args", C.CL_DEVICE_MAX_READ_IMAGE_ARGS)
+	device.Max_write_image_args = query.uint("Max_write_image_args", C.CL_DEVICE_MAX_WRITE_IMAGE_ARGS)
+	device.Max_samplers = query.uint("Max_samplers", C.CL_DEVICE_MAX_SAMPLERS)
+	device.Image2d_max_width = query.size("Image2d_max_width", C.CL_DEVICE_IMAGE2D_MAX_WIDTH)
+	device.Image2d_max_height = query.size("Image2d_max_height", C.CL_DEVICE_IMAGE2D_MAX_HEIGHT)
+	device.Image3d_max_width = query.size("Image3d_max_width", C.CL_DEVICE_IMAGE3D_MAX_WIDTH)
+	device.Image3d_max_height = query.size("Image3d_max_height", C.CL_DEVICE_IMAGE3D_MAX_HEIGHT)
+	device.Image3d_max_depth = query.size("Image3d_max_depth", C.CL_DEVICE_IMAGE3D_MAX_DEPTH)
+
+	// vector widths
+	device.Preferred_vector_width = query.vectorWidths("Preferred_vector_width", [7]C.cl_device_info{
+		C.CL_DEVICE_PREFERRED_VECTOR_WIDTH_CHAR, C.CL_DEVICE_PREFERRED_VECTOR_WIDTH_SHORT,
+		C.CL_DEVICE_PREFERRED_VECTOR_WIDTH_INT, C.CL_DEVICE_PREFERRED_VECTOR_WIDTH_LONG,
+		C.CL_DEVICE_PREFERRED_VECTOR_WIDTH_FLOAT, C.CL_DEVICE_PREFERRED_VECTOR_WIDTH_DOUBLE,
+		C.CL_DEVICE_PREFERRED_VECTOR_WIDTH_HALF,
+	})
+	device.Native_vector_width = query.vectorWidths("Native_vector_width", [7]C.cl_device_info{
+		C.CL_DEVICE_NATIVE_VECTOR_WIDTH_CHAR, C.CL_DEVICE_NATIVE_VECTOR_WIDTH_SHORT,
+		C.CL_DEVICE_NATIVE_VECTOR_WIDTH_INT, C.CL_DEVICE_NATIVE_VECTOR_WIDTH_LONG,
+		C.CL_DEVICE_NATIVE_VECTOR_WIDTH_FLOAT, C.CL_DEVICE_NATIVE_VECTOR_WIDTH_DOUBLE,
+		C.CL_DEVICE_NATIVE_VECTOR_WIDTH_HALF,
+	})
+
+	// misc
+	device.Address_bits = query.uint("Address_bits", C.CL_DEVICE_ADDRESS_BITS)
+	device.Endian_little = query.bool("Endian_little", C.CL_DEVICE_ENDIAN_LITTLE)
+	device.Host_unified_memory = query.bool("Host_unified_memory", C.CL_DEVICE_HOST_UNIFIED_MEMORY)
+	device.Error_correction_support = query.bool("Error_correction_support", C.CL_DEVICE_ERROR_CORRECTION_SUPPORT)
+	device.Available = query.bool("Available", C.CL_DEVICE_AVAILABLE)
+	device.Compiler_available = query.bool("Compiler_available", C.CL_DEVICE_COMPILER_AVAILABLE)
+	device.Profiling_timer_resolution = uint64(query.size("Profiling_timer_resolution", C.CL_DEVICE_PROFILING_TIMER_RESOLUTION))
+	device.OpenCL_c_version = query.string("OpenCL_c_version", C.CL_DEVICE_OPENCL_C_VERSION)
+	device.Extensions = strings.Fields(query.string("Extensions", C.CL_DEVICE_EXTENSIONS))
+	device.Extension_set = NewExtensionSet(device.Extensions...)
+	device.Parsed_version, _ = ParseVersion(device.Version)
+	device.Parsed_c_version, _ = ParseVersion(device.OpenCL_c_version)
+
+	// floating point
+	device.Single_fp_config = FPConfig(query.ulong("Single_fp_config", C.CL_DEVICE_SINGLE_FP_CONFIG))
+	device.Double_fp_config = FPConfig(query.ulong("Double_fp_config", C.CL_DEVICE_DOUBLE_FP_CONFIG))
+	// CL_DEVICE_HALF_FP_CONFIG is invalid without cl_khr_fp16.
+	if device.Extension_set.Has("cl_khr_fp16") {
+		device.Half_fp_config = FPConfig(query.ulong("Half_fp_config", clDeviceHalfFPConfig))
 	}
-	device.Vendor = string(info[:len(info)-1])
 
-	// driver_version
-	err = C.clGetDeviceInfo(device_id, C.CL_DRIVER_VERSION, 0, nil, &infoSize)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+	// OpenCL 1.2 parameters are invalid on older devices.
+	if device.AtLeast(1, 2) {
+		device.Image_max_buffer_size = query.size("Image_max_buffer_size", C.CL_DEVICE_IMAGE_MAX_BUFFER_SIZE)
+		device.Image_max_array_size = query.size("Image_max_array_size", C.CL_DEVICE_IMAGE_MAX_ARRAY_SIZE)
+		device.Built_in_kernels = splitKernelNames(query.string("Built_in_kernels", C.CL_DEVICE_BUILT_IN_KERNELS))
+		device.Partition_max_sub_devices = query.uint("Partition_max_sub_devices", C.CL_DEVICE_PARTITION_MAX_SUB_DEVICES)
+		device.Partition_properties = query.partitionProperties("Partition_properties")
 	}
-	info = make([]byte, infoSize, infoSize)
-	err = C.clGetDeviceInfo(device_id, C.CL_DRIVER_VERSION, infoSize, unsafe.Pointer(&info[0]), nil)
-	if err != C.CL_SUCCESS {
-		return &device, fmt.Errorf("clGetDeviceInfo Err: %v", err)
+
+	if device.AtLeast(3, 0) {
+		device.OpenCL_c_features = decodeNameVersions(query.bytes("OpenCL_c_features", clDeviceOpenCLCFeatures))
 	}
-	device.Driver_version = string(info[:len(info)-1])
 
-	return &device, nil
+	device.Info_errors = query.errors
+	return &device, query.err()
 }
 
-func getOnePlatform(platform_id C.cl_platform_id) (*OpenCLPlatform, error) {
-	var platform = OpenCLPlatform{Platform_id: platform_id}
+// splitKernelNames splits a semicolon separated kernel list like CL_DEVICE_BUILT_IN_KERNELS.
+func splitKernelNames(s string) []string {
+	var kernels []string
+	for _, kernel := range strings.Split(s, ";") {
+		if kernel = strings.TrimSpace(kernel); kernel != "" {
+			kernels = append(kernels, kernel)
+		}
+	}
+	return kernels
+}
 
+// getPlatformInfoString queries a string parameter of the platform.
+func getPlatformInfoString(platform_id C.cl_platform_id, param C.cl_platform_info) (string, error) {
 	var infoSize C.size_t
-	// name
-	var err = C.clGetPlatformInfo(platform_id, C.CL_PLATFORM_NAME, 0, nil, &infoSize)
+	var err = C.clGetPlatformInfo(platform_id, param, 0, nil, &infoSize)
 	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetPlatformInfo Err: %v", err)
+		return "", clError("clGetPlatformInfo", err)
+	}
+	if infoSize <= 1 {
+		return "", nil
 	}
 	var info = make([]byte, infoSize, infoSize)
-	err = C.clGetPlatformInfo(platform_id, C.CL_PLATFORM_NAME, infoSize, unsafe.Pointer(&info[0]), nil)
+	err = C.clGetPlatformInfo(platform_id, param, infoSize, unsafe.Pointer(&info[0]), nil)
 	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetPlatformInfo Err: %v", err)
+		return "", clError("clGetPlatformInfo", err)
 	}
-	platform.Name = string(info[:len(info)-1])
+	return strings.Trim(string(info[:len(info)-1]), " "), nil
+}
 
-	// profile
-	err = C.clGetPlatformInfo(platform_id, C.CL_PLATFORM_PROFILE, 0, nil, &infoSize)
-	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetPlatformInfo Err: %v", err)
-	}
-	info = make([]byte, infoSize, infoSize)
-	err = C.clGetPlatformInfo(platform_id, C.CL_PLATFORM_PROFILE, infoSize, unsafe.Pointer(&info[0]), nil)
-	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetPlatformInfo Err: %v", err)
-	}
-	platform.Profile = string(info[:len(info)-1])
+// InfoDiagnostic reports a platform or device that Info could not query completely.
+type InfoDiagnostic struct {
+	Platform     int // index of the platform in the clGetPlatformIDs list
+	Device       int // index of the device on the platform, -1 if the platform itself failed
+	PlatformName string
+	DeviceName   string
+	Skipped      bool // the platform or device is missing from the result
+	Err          error
+}
 
-	// version
-	err = C.clGetPlatformInfo(platform_id, C.CL_PLATFORM_VERSION, 0, nil, &infoSize)
-	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetPlatformInfo Err: %v", err)
+func (diag InfoDiagnostic) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "platform %d", diag.Platform)
+	if diag.PlatformName != "" {
+		fmt.Fprintf(&b, " (%s)", diag.PlatformName)
+	}
+	if diag.Device >= 0 {
+		fmt.Fprintf(&b, " device %d", diag.Device)
+		if diag.DeviceName != "" {
+			fmt.Fprintf(&b, " (%s)", diag.DeviceName)
+		}
+	}
+	if diag.Skipped {
+		b.WriteString(" skipped")
+	}
+	fmt.Fprintf(&b, ": %v", diag.Err)
+	return b.String()
+}
+
+func (diag InfoDiagnostic) Unwrap() error {
+	return diag.Err
+}
+
+// requiredDeviceFields are the parameters without which a device is left out of Info.
+var requiredDeviceFields = []string{"Device_type", "Name", "Version"}
+
+// getOnePlatform queries a platform and its devices.
+// It fails only if the platform itself cannot be queried; devices that fail are reported as diagnostics.
+func getOnePlatform(platform_id C.cl_platform_id) (*OpenCLPlatform, []InfoDiagnostic, error) {
+	var platform = OpenCLPlatform{Platform_id: PlatformID{platform_id}}
+
+	var err error
+	if platform.Name, err = getPlatformInfoString(platform_id, C.CL_PLATFORM_NAME); err != nil {
+		return &platform, nil, err
 	}
-	info = make([]byte, infoSize, infoSize)
-	err = C.clGetPlatformInfo(platform_id, C.CL_PLATFORM_VERSION, infoSize, unsafe.Pointer(&info[0]), nil)
-	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetPlatformInfo Err: %v", err)
+	if platform.Profile, err = getPlatformInfoString(platform_id, C.CL_PLATFORM_PROFILE); err != nil {
+		return &platform, nil, err
 	}
-	platform.Version = strings.Trim(string(info[:len(info)-1]), " ")
-
-	// vendor
-	err = C.clGetPlatformInfo(platform_id, C.CL_PLATFORM_VENDOR, 0, nil, &infoSize)
-	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetPlatformInfo Err: %v", err)
+	if platform.Version, err = getPlatformInfoString(platform_id, C.CL_PLATFORM_VERSION); err != nil {
+		return &platform, nil, err
 	}
-	info = make([]byte, infoSize, infoSize)
-	err = C.clGetPlatformInfo(platform_id, C.CL_PLATFORM_VENDOR, infoSize, unsafe.Pointer(&info[0]), nil)
-	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetPlatformInfo Err: %v", err)
+	if platform.Vendor, err = getPlatformInfoString(platform_id, C.CL_PLATFORM_VENDOR); err != nil {
+		return &platform, nil, err
 	}
-	platform.Vendor = string(info[:len(info)-1])
 
 	// devices
-	err = C.clGetDeviceIDs(platform_id, C.CL_DEVICE_TYPE_ALL, 0, nil, &platform.Device_count)
-	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetDeviceIDs Err: %v", err)
+	var device_count C.cl_uint
+	var clErr = C.clGetDeviceIDs(platform_id, C.CL_DEVICE_TYPE_ALL, 0, nil, &device_count)
+	if clErr == C.CL_DEVICE_NOT_FOUND || (clErr == C.CL_SUCCESS && device_count == 0) {
+		return &platform, nil, nil
 	}
-	var device_ids = make([]C.cl_device_id, platform.Device_count, platform.Device_count)
-	err = C.clGetDeviceIDs(platform_id, C.CL_DEVICE_TYPE_ALL, platform.Device_count, &device_ids[0], nil)
-	if err != C.CL_SUCCESS {
-		return &platform, fmt.Errorf("clGetDeviceIDs Err: %v", err)
+	if clErr != C.CL_SUCCESS {
+		return &platform, nil, clError("clGetDeviceIDs", clErr)
+	}
+	var device_ids = make([]C.cl_device_id, device_count, device_count)
+	clErr = C.clGetDeviceIDs(platform_id, C.CL_DEVICE_TYPE_ALL, device_count, &device_ids[0], nil)
+	if clErr != C.CL_SUCCESS {
+		return &platform, nil, clError("clGetDeviceIDs", clErr)
 	}
 
-	for _, device_id := range device_ids {
-		device, _ := getOneDevie(platform.Platform_id, device_id)
-		platform.Devices = append(platform.Devices, device)
+	var diags []InfoDiagnostic
+	for i, device_id := range device_ids {
+		device, err := getOneDevie(platform_id, device_id)
+		if diag := platform.addDevice(i, device, err); diag != nil {
+			diags = append(diags, *diag)
+		}
 	}
 
-	return &platform, nil
+	return &platform, diags, nil
+}
+
+// addDevice adds the device at index of the platform, unless its query failed for a required field.
+// It returns the diagnostic of a failed query.
+func (platform *OpenCLPlatform) addDevice(index int, device *OpenCLDevice, err error) *InfoDiagnostic {
+	var diag *InfoDiagnostic
+	if err != nil {
+		diag = &InfoDiagnostic{Device: index, DeviceName: device.Name, Err: err}
+		for _, field := range requiredDeviceFields {
+			if device.Info_errors[field] != nil {
+				diag.Skipped = true
+			}
+		}
+	}
+	if diag == nil || !diag.Skipped {
+		platform.Devices = append(platform.Devices, device)
+	}
+	platform.Device_count = uint32(len(platform.Devices))
+	return diag
 }
 
+// Info queries the OpenCL platforms and their devices.
+// Platforms and devices that cannot be queried are left out or returned incomplete, and reported in
+// OpenCLInfo.Diagnostics; an error is only returned if the platforms cannot be listed at all.
 func Info() (*OpenCLInfo, error) {
 	var info OpenCLInfo
 
-	var err = C.clGetPlatformIDs(0, nil, &info.Platform_count)
+	var platform_count C.cl_uint
+	var err = C.clGetPlatformIDs(0, nil, &platform_count)
 	if err != C.CL_SUCCESS {
-		return &info, fmt.Errorf("clGetPlatformIDs Err: %v", err)
+		return &info, clError("clGetPlatformIDs", err)
 	}
-
-	if info.Platform_count == 0 {
+	if platform_count == 0 {
 		return &info, nil
 	}
 
-	var platform_ids = make([]C.cl_platform_id, info.Platform_count, info.Platform_count)
-	err = C.clGetPlatformIDs(info.Platform_count, &platform_ids[0], nil)
+	var platform_ids = make([]C.cl_platform_id, platform_count, platform_count)
+	err = C.clGetPlatformIDs(platform_count, &platform_ids[0], nil)
 	if err != C.CL_SUCCESS {
-		return &info, fmt.Errorf("clGetPlatformIDs Err: %v", err)
+		return &info, clError("clGetPlatformIDs", err)
 	}
 
-	for _, platform_id := range platform_ids {
-		platform, _ := getOnePlatform(platform_id)
-		info.Platforms = append(info.Platforms, platform)
+	for i, platform_id := range platform_ids {
+		platform, diags, err := getOnePlatform(platform_id)
+		info.addPlatform(i, platform, diags, err)
 	}
 
 	return &info, nil
 }
+
+// addPlatform adds the platform at index with the diagnostics of its devices, or only a diagnostic
+// if the platform itself could not be queried.
+func (info *OpenCLInfo) addPlatform(index int, platform *OpenCLPlatform, diags []InfoDiagnostic, err error) {
+	if err != nil {
+		info.Diagnostics = append(info.Diagnostics, InfoDiagnostic{
+			Platform: index, Device: -1, PlatformName: platform.Name, Skipped: true, Err: err})
+		return
+	}
+	for _, diag := range diags {
+		diag.Platform = index
+		diag.PlatformName = platform.Name
+		info.Diagnostics = append(info.Diagnostics, diag)
+	}
+	info.Platforms = append(info.Platforms, platform)
+	info.Platform_count = uint32(len(info.Platforms))
+}
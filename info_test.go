@@ -0,0 +1,109 @@
+package opencl
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFPConfigString(t *testing.T) {
+	var tests = []struct {
+		config FPConfig
+		want   string
+	}{
+		{0, "None"},
+		{FPDenorm | FPInfNaN | FPRoundToNearest, "Denorm|INF/NaN|RoundToNearest"},
+		{FPFMA | 1<<20, "FMA|0x100000"},
+	}
+	for _, test := range tests {
+		if got := test.config.String(); got != test.want {
+			t.Errorf("FPConfig(%#x).String() = %q, want %q", uint64(test.config), got, test.want)
+		}
+	}
+}
+
+func TestSplitKernelNames(t *testing.T) {
+	var got = splitKernelNames(" vme_block;block_motion_estimate ;;")
+	if want := []string{"vme_block", "block_motion_estimate"}; !slices.Equal(got, want) {
+		t.Errorf("splitKernelNames = %q, want %q", got, want)
+	}
+	if got := splitKernelNames(""); got != nil {
+		t.Errorf("splitKernelNames(\"\") = %q, want nil", got)
+	}
+}
+
+func TestDeviceTypeString(t *testing.T) {
+	var tests = []struct {
+		deviceType DeviceType
+		want       string
+	}{
+		{0, "None"},
+		{DeviceTypeGPU, "GPU"},
+		{DeviceTypeCPU | DeviceTypeAccelerator, "CPU|Accelerator"},
+		{DeviceTypeGPU | 1<<40, "GPU|0x10000000000"},
+	}
+	for _, test := range tests {
+		if got := test.deviceType.String(); got != test.want {
+			t.Errorf("DeviceType(%#x).String() = %q, want %q", uint64(test.deviceType), got, test.want)
+		}
+	}
+}
+
+func TestInfoDiagnostic(t *testing.T) {
+	var platformDiag = InfoDiagnostic{Platform: 1, Device: -1, Skipped: true,
+		Err: &Error{Code: ErrOutOfHostMemory, Name: ErrOutOfHostMemory.Name(), Func: "clGetPlatformInfo"}}
+	if got, want := platformDiag.Error(), "platform 1 skipped: clGetPlatformInfo Err: CL_OUT_OF_HOST_MEMORY (-6)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(platformDiag, ErrOutOfHostMemory) {
+		t.Errorf("errors.Is(%v, ErrOutOfHostMemory) = false", platformDiag)
+	}
+
+	var deviceDiag = InfoDiagnostic{Platform: 0, PlatformName: "Vendor X", Device: 2, DeviceName: "gpu0",
+		Err: errors.New("Half_fp_config: failed")}
+	if got, want := deviceDiag.Error(), "platform 0 (Vendor X) device 2 (gpu0): Half_fp_config: failed"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestInfoCounts(t *testing.T) {
+	var platform = &OpenCLPlatform{Name: "Vendor X"}
+	var queryErr = errors.New("query failed")
+	platform.addDevice(0, &OpenCLDevice{Name: "gpu0"}, nil)
+	var skipped = platform.addDevice(1, &OpenCLDevice{Info_errors: map[string]error{"Name": queryErr}}, queryErr)
+	var incomplete = platform.addDevice(2, &OpenCLDevice{Name: "gpu2", Info_errors: map[string]error{"Half_fp_config": queryErr}}, queryErr)
+	if skipped == nil || !skipped.Skipped || incomplete == nil || incomplete.Skipped {
+		t.Errorf("diagnostics %+v and %+v, want the second device skipped", skipped, incomplete)
+	}
+	if platform.Device_count != 2 || len(platform.Devices) != 2 {
+		t.Errorf("Device_count %d with %d devices, want 2", platform.Device_count, len(platform.Devices))
+	}
+
+	var info OpenCLInfo
+	info.addPlatform(0, platform, []InfoDiagnostic{*skipped, *incomplete}, nil)
+	info.addPlatform(1, &OpenCLPlatform{Name: "Vendor Y"}, nil, queryErr)
+	if info.Platform_count != 1 || len(info.Platforms) != 1 {
+		t.Errorf("Platform_count %d with %d platforms, want 1", info.Platform_count, len(info.Platforms))
+	}
+	if len(info.Diagnostics) != 3 || info.Diagnostics[0].PlatformName != "Vendor X" || info.Diagnostics[2].Device != -1 {
+		t.Errorf("diagnostics %v", info.Diagnostics)
+	}
+}
+
+func TestDeviceQueryFields(t *testing.T) {
+	// every query of a NULL device fails, and is reported under its own field
+	device, err := getOneDevie(nil, nil)
+	if err == nil {
+		t.Fatal("querying a NULL device succeeded")
+	}
+	for _, field := range []string{"Name", "Preferred_vector_width.Char", "Preferred_vector_width.Half", "Native_vector_width.Double"} {
+		if device.Info_errors[field] == nil {
+			t.Errorf("no error recorded for %s in %v", field, device.Info_errors)
+		}
+	}
+	var lines = strings.Split(err.Error(), "\n")
+	if len(lines) != len(device.Info_errors) {
+		t.Errorf("%d errors reported for %d fields:\n%v", len(lines), len(device.Info_errors), err)
+	}
+}
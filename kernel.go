@@ -0,0 +1,334 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AddressQualifier is the address space of a kernel argument.
+type AddressQualifier uint32
+
+const (
+	AddressGlobal   AddressQualifier = C.CL_KERNEL_ARG_ADDRESS_GLOBAL
+	AddressLocal    AddressQualifier = C.CL_KERNEL_ARG_ADDRESS_LOCAL
+	AddressConstant AddressQualifier = C.CL_KERNEL_ARG_ADDRESS_CONSTANT
+	AddressPrivate  AddressQualifier = C.CL_KERNEL_ARG_ADDRESS_PRIVATE
+)
+
+func (q AddressQualifier) String() string {
+	switch q {
+	case AddressGlobal:
+		return "__global"
+	case AddressLocal:
+		return "__local"
+	case AddressConstant:
+		return "__constant"
+	case AddressPrivate:
+		return "__private"
+	}
+	return "AddressQualifier(" + strconv.Itoa(int(q)) + ")"
+}
+
+// AccessQualifier is the access qualifier of an image kernel argument.
+type AccessQualifier uint32
+
+const (
+	AccessReadOnly  AccessQualifier = C.CL_KERNEL_ARG_ACCESS_READ_ONLY
+	AccessWriteOnly AccessQualifier = C.CL_KERNEL_ARG_ACCESS_WRITE_ONLY
+	AccessReadWrite AccessQualifier = C.CL_KERNEL_ARG_ACCESS_READ_WRITE
+	AccessNone      AccessQualifier = C.CL_KERNEL_ARG_ACCESS_NONE
+)
+
+func (q AccessQualifier) String() string {
+	switch q {
+	case AccessReadOnly:
+		return "__read_only"
+	case AccessWriteOnly:
+		return "__write_only"
+	case AccessReadWrite:
+		return "__read_write"
+	case AccessNone:
+		return ""
+	}
+	return "AccessQualifier(" + strconv.Itoa(int(q)) + ")"
+}
+
+// KernelArg describes a kernel argument as reported by clGetKernelArgInfo.
+type KernelArg struct {
+	Name             string
+	TypeName         string
+	AddressQualifier AddressQualifier
+	AccessQualifier  AccessQualifier
+}
+
+func (arg KernelArg) String() string {
+	var s = arg.TypeName + " " + arg.Name
+	if arg.AddressQualifier != AddressPrivate {
+		s = arg.AddressQualifier.String() + " " + s
+	}
+	if arg.AccessQualifier != AccessNone {
+		s = arg.AccessQualifier.String() + " " + s
+	}
+	return s
+}
+
+// Kernel represents an OpenCL kernel of a Program.
+//
+// clSetKernelArg is not thread-safe, so a kernel enqueued with arguments while another goroutine
+// is setting the arguments of the same kernel is enqueued through a clone. Clones are created on
+// demand and reused, so there are at most as many as concurrent enqueues of the kernel.
+type Kernel struct {
+	kernel  BackendKernel
+	Name    string
+	NumArgs int
+	// Args is only available if the program was built with the -cl-kernel-arg-info option.
+	Args []KernelArg
+	handle
+
+	mu     sync.Mutex      // guards the arguments of kernel
+	poolMu sync.Mutex      // guards clones and idle
+	clones []BackendKernel // all clones, released with the kernel
+	idle   []BackendKernel // clones not in use
+
+	trace     *tracer // the trace of the runner that built the kernel, if any
+	programID uint64
+}
+
+// Release releases the kernel. Calling Release more than once has no effect.
+// Kernels are released with their Program, so Release is only needed to drop a kernel early;
+// it must not be used by a runner afterwards.
+func (kernel *Kernel) Release() error {
+	return kernel.releaseHandle()
+}
+
+// newKernel creates the named kernel and queries its signature.
+func newKernel(t *tracker, program BackendProgram, kernelName string) (*Kernel, error) {
+	impl, err := program.NewKernel(kernelName)
+	if err != nil {
+		return nil, err
+	}
+	var kernel = &Kernel{kernel: impl, Name: kernelName}
+	kernel.NumArgs, err = impl.NumArgs()
+	if err != nil {
+		impl.Release()
+		return nil, err
+	}
+	kernel.obj = t.track("kernel", kernelName, "clReleaseKernel", true, func() ErrorCode {
+		kernel.poolMu.Lock()
+		defer kernel.poolMu.Unlock()
+		for _, clone := range kernel.clones {
+			clone.Release()
+		}
+		kernel.clones, kernel.idle = nil, nil
+		return impl.Release()
+	})
+
+	for i := 0; i < kernel.NumArgs; i++ {
+		arg, err := impl.ArgInfo(i)
+		if err != nil {
+			// CL_KERNEL_ARG_INFO_NOT_AVAILABLE without -cl-kernel-arg-info
+			kernel.Args = nil
+			break
+		}
+		kernel.Args = append(kernel.Args, arg)
+	}
+
+	return kernel, nil
+}
+
+// Kernel returns the kernel with the given name from any program of the runner.
+func (runner *OpenCLRunner) Kernel(kernelName string) (*Kernel, error) {
+	return runner.kernel("Kernel", kernelName)
+}
+
+// KernelArgError reports a kernel parameter that does not match the kernel signature.
+// It unwraps to the OpenCL error code the driver would have returned.
+type KernelArgError struct {
+	Kernel string
+	Index  int // -1 if the number of arguments is wrong
+	Arg    *KernelArg
+	Reason string
+	Code   ErrorCode
+}
+
+func (e *KernelArgError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("kernel %q: %s", e.Kernel, e.Reason)
+	}
+	if e.Arg != nil {
+		return fmt.Sprintf("kernel %q argument %d (%s): %s", e.Kernel, e.Index, e.Arg, e.Reason)
+	}
+	return fmt.Sprintf("kernel %q argument %d: %s", e.Kernel, e.Index, e.Reason)
+}
+
+func (e *KernelArgError) Unwrap() error {
+	return e.Code
+}
+
+// Validate checks the parameters against the kernel signature.
+// The number of parameters is always checked; buffer, local and scalar kinds and scalar sizes
+// are only checked if argument info is available.
+func (kernel *Kernel) Validate(args []KernelParam) error {
+	if len(args) != kernel.NumArgs {
+		return &KernelArgError{Kernel: kernel.Name, Index: -1, Code: ErrInvalidKernelArgs,
+			Reason: fmt.Sprintf("got %d parameters, expected %d", len(args), kernel.NumArgs)}
+	}
+	if kernel.Args == nil {
+		return nil
+	}
+
+	for i, param := range args {
+		var arg = &kernel.Args[i]
+		var argError = func(code ErrorCode, format string, a ...any) error {
+			return &KernelArgError{Kernel: kernel.Name, Index: i, Arg: arg, Code: code, Reason: fmt.Sprintf(format, a...)}
+		}
+
+		switch {
+		case arg.AddressQualifier == AddressLocal:
+			if param.Pointer != nil || param.buffer != nil {
+				return argError(ErrInvalidArgValue, "expected a local memory size, use LocalParam")
+			}
+			if param.Size == 0 {
+				return argError(ErrInvalidArgSize, "local memory size is zero")
+			}
+		case arg.AddressQualifier == AddressGlobal || arg.AddressQualifier == AddressConstant || isMemObjectType(arg.TypeName):
+			if param.buffer == nil {
+				return argError(ErrInvalidArgValue, "expected a buffer, use BufferParam")
+			}
+		default:
+			if param.buffer != nil {
+				return argError(ErrInvalidArgValue, "expected a scalar, got a buffer")
+			}
+			if param.Pointer == nil {
+				return argError(ErrInvalidArgValue, "expected a scalar, got a local memory size")
+			}
+			if size, ok := scalarSize(arg.TypeName); ok && size != param.Size {
+				return argError(ErrInvalidArgSize, "expected %d bytes, got %d", size, param.Size)
+			}
+		}
+	}
+	return nil
+}
+
+// SetArgs validates and sets the kernel arguments.
+// An empty list is accepted and leaves previously set arguments in place.
+func (kernel *Kernel) SetArgs(args []KernelParam) error {
+	if len(args) == 0 {
+		return nil
+	}
+	var start = time.Now()
+	var err = kernel.Validate(args)
+	if err == nil {
+		kernel.mu.Lock()
+		err = kernel.setArgs(kernel.kernel, args)
+		kernel.mu.Unlock()
+	}
+	if kernel.trace != nil {
+		kernel.trace.record(start, &TraceRecord{Op: TraceSetArgs, Program: kernel.programID, Kernel: kernel.Name, Args: traceArgs(args)}, err)
+	}
+	return err
+}
+
+func (kernel *Kernel) setArgs(impl BackendKernel, args []KernelParam) error {
+	for i, arg := range args {
+		if err := impl.SetArg(i, arg); err != nil {
+			return withKernel(err, kernel.Name)
+		}
+	}
+	return nil
+}
+
+// acquire returns the kernel or a clone with the arguments set, ready to be enqueued,
+// and a function to call once it is enqueued. The arguments are captured by the enqueue,
+// so the instance can be reused right after.
+// Without arguments, the ones set with SetArgs are used, so it waits for the kernel itself.
+func (kernel *Kernel) acquire(args []KernelParam) (BackendKernel, func(), error) {
+	if len(args) == 0 {
+		kernel.mu.Lock()
+		return kernel.kernel, kernel.mu.Unlock, nil
+	}
+	if err := kernel.Validate(args); err != nil {
+		return nil, nil, err
+	}
+
+	var impl BackendKernel
+	var done func()
+	if kernel.mu.TryLock() {
+		impl, done = kernel.kernel, kernel.mu.Unlock
+	} else {
+		clone, err := kernel.clone()
+		if err != nil {
+			return nil, nil, err
+		}
+		impl, done = clone, func() {
+			kernel.poolMu.Lock()
+			kernel.idle = append(kernel.idle, clone)
+			kernel.poolMu.Unlock()
+		}
+	}
+	if err := kernel.setArgs(impl, args); err != nil {
+		done()
+		return nil, nil, err
+	}
+	return impl, done, nil
+}
+
+// clone returns an idle clone of the kernel, or creates a new one.
+func (kernel *Kernel) clone() (BackendKernel, error) {
+	kernel.poolMu.Lock()
+	defer kernel.poolMu.Unlock()
+	if n := len(kernel.idle); n > 0 {
+		var clone = kernel.idle[n-1]
+		kernel.idle = kernel.idle[:n-1]
+		return clone, nil
+	}
+
+	clone, err := kernel.kernel.Clone()
+	if err != nil {
+		return nil, withKernel(err, kernel.Name)
+	}
+	kernel.clones = append(kernel.clones, clone)
+	return clone, nil
+}
+
+func isMemObjectType(typeName string) bool {
+	return strings.HasSuffix(typeName, "*") || strings.HasPrefix(typeName, "image") || typeName == "pipe"
+}
+
+var scalarBaseSizes = map[string]uintptr{
+	"char": 1, "uchar": 1, "bool": 1,
+	"short": 2, "ushort": 2, "half": 2,
+	"int": 4, "uint": 4, "float": 4,
+	"long": 8, "ulong": 8, "double": 8,
+}
+
+// scalarSize returns the size of a built-in scalar or vector type such as "float4".
+// ok is false for types whose size is not known, e.g. structs or size_t.
+func scalarSize(typeName string) (size uintptr, ok bool) {
+	typeName = strings.TrimPrefix(strings.TrimSpace(typeName), "unsigned ")
+	var base = strings.TrimRight(typeName, "0123456789")
+	baseSize, ok := scalarBaseSizes[base]
+	if !ok {
+		return 0, false
+	}
+	if base == typeName {
+		return baseSize, true
+	}
+	n, err := strconv.Atoi(typeName[len(base):])
+	if err != nil {
+		return 0, false
+	}
+	switch n {
+	case 2, 4, 8, 16:
+		return baseSize * uintptr(n), true
+	case 3:
+		return baseSize * 4, true
+	}
+	return 0, false
+}
@@ -0,0 +1,57 @@
+package opencl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScalarSize(t *testing.T) {
+	for typeName, expected := range map[string]uintptr{
+		"int": 4, "uchar": 1, "float4": 16, "double2": 16, "short3": 8, "ulong16": 128, "unsigned int": 4,
+	} {
+		if size, ok := scalarSize(typeName); !ok || size != expected {
+			t.Errorf("scalarSize(%q) = %d, %v", typeName, size, ok)
+		}
+	}
+	for _, typeName := range []string{"size_t", "struct point", "float5", "float*"} {
+		if _, ok := scalarSize(typeName); ok {
+			t.Errorf("scalarSize(%q) is known", typeName)
+		}
+	}
+}
+
+func TestKernelValidate(t *testing.T) {
+	kernel := &Kernel{Name: "scale", NumArgs: 3, Args: []KernelArg{
+		{Name: "data", TypeName: "float*", AddressQualifier: AddressGlobal, AccessQualifier: AccessNone},
+		{Name: "scratch", TypeName: "float*", AddressQualifier: AddressLocal, AccessQualifier: AccessNone},
+		{Name: "factor", TypeName: "float", AddressQualifier: AddressPrivate, AccessQualifier: AccessNone},
+	}}
+	buffer := &Buffer{}
+	factor := float32(2)
+	wrongFactor := float64(2)
+
+	if err := kernel.Validate([]KernelParam{BufferParam(buffer), LocalParam(64), Param(&factor)}); err != nil {
+		t.Fatal("Validate err:", err)
+	}
+
+	for _, c := range []struct {
+		args []KernelParam
+		code ErrorCode
+	}{
+		{[]KernelParam{BufferParam(buffer)}, ErrInvalidKernelArgs},
+		{[]KernelParam{Param(&factor), LocalParam(64), Param(&factor)}, ErrInvalidArgValue},
+		{[]KernelParam{BufferParam(buffer), Param(&factor), Param(&factor)}, ErrInvalidArgValue},
+		{[]KernelParam{BufferParam(buffer), LocalParam(64), Param(&wrongFactor)}, ErrInvalidArgSize},
+	} {
+		err := kernel.Validate(c.args)
+		if !errors.Is(err, c.code) {
+			t.Errorf("Validate returned %v, expected %v", err, c.code)
+		}
+	}
+
+	// without argument info only the count is checked
+	kernel.Args = nil
+	if err := kernel.Validate([]KernelParam{Param(&factor), Param(&factor), Param(&factor)}); err != nil {
+		t.Fatal("Validate err:", err)
+	}
+}
@@ -0,0 +1,289 @@
+package opencl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrPoolClosed is returned by RunnerPool.Acquire once the pool is closed.
+var ErrPoolClosed = errors.New("RunnerPool is closed")
+
+// ProgramSource is a program compiled on every runner of a RunnerPool, with the arguments of CompileKernels.
+// If Kernels is empty, it is compiled with CompileAllKernels instead.
+type ProgramSource struct {
+	Sources []string
+	Kernels []string
+	Options string
+}
+
+// compile compiles the program on the runner.
+func (program *ProgramSource) compile(runner *OpenCLRunner) error {
+	if len(program.Kernels) == 0 {
+		return runner.CompileAllKernels(program.Sources, program.Options)
+	}
+	return runner.CompileKernels(program.Sources, program.Kernels, program.Options)
+}
+
+// Balance is the strategy RunnerPool.Acquire uses to choose a device.
+type Balance int
+
+const (
+	// BalanceQueueDepth chooses the device with the fewest acquired runners relative to its limit,
+	// the best scored one among equals.
+	BalanceQueueDepth Balance = iota
+	// BalanceScore chooses the best scored device that is below its limit.
+	BalanceScore
+)
+
+// PoolOption configures the pool created by NewRunnerPool.
+type PoolOption func(*poolConfig)
+
+type poolConfig struct {
+	limit         int
+	balance       Balance
+	score         func(device *OpenCLDevice) float64
+	runnerOptions []RunnerOption
+}
+
+// WithDeviceLimit sets how many times the runner of a device can be acquired at once, 1 by default.
+// Runners are safe for concurrent use, so a limit above 1 lets several goroutines share a device.
+func WithDeviceLimit(limit int) PoolOption {
+	return func(config *poolConfig) {
+		config.limit = limit
+	}
+}
+
+// WithBalance sets the strategy used to choose a device, BalanceQueueDepth by default.
+func WithBalance(balance Balance) PoolOption {
+	return func(config *poolConfig) {
+		config.balance = balance
+	}
+}
+
+// WithDeviceScore sets the score that ranks devices, ComputeScore by default.
+func WithDeviceScore(score func(device *OpenCLDevice) float64) PoolOption {
+	return func(config *poolConfig) {
+		config.score = score
+	}
+}
+
+// WithPoolRunnerOptions sets the options InitRunner is called with for each device.
+func WithPoolRunnerOptions(opts ...RunnerOption) PoolOption {
+	return func(config *poolConfig) {
+		config.runnerOptions = opts
+	}
+}
+
+// RunnerPool owns one runner per device, all with the same programs, and hands them out
+// to goroutines with Acquire and Release.
+type RunnerPool struct {
+	mu      sync.Mutex
+	entries []*poolEntry // best scored first
+	runners map[*OpenCLRunner]*poolEntry
+	balance Balance
+	changed chan struct{} // closed and replaced when a runner is released or the pool is closed
+	closed  bool
+	freed   bool
+}
+
+type poolEntry struct {
+	runner   *OpenCLRunner
+	score    float64
+	limit    int
+	inUse    int
+	acquired uint64
+}
+
+// PoolStats describes the use of a device of a RunnerPool.
+type PoolStats struct {
+	Device   *OpenCLDevice
+	InUse    int    // runners acquired and not released yet
+	Limit    int    // the maximum of InUse
+	Acquired uint64 // total number of acquisitions
+}
+
+// NewRunnerPool initializes a runner for each device and compiles the programs on it.
+// If a runner cannot be initialized or a program fails to build, all runners are freed and the error is returned.
+func NewRunnerPool(devices []*OpenCLDevice, programs []ProgramSource, opts ...PoolOption) (*RunnerPool, error) {
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("NewRunnerPool Err: no devices")
+	}
+	var config = poolConfig{limit: 1, score: ComputeScore}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.limit < 1 {
+		return nil, fmt.Errorf("NewRunnerPool Err: device limit %d is less than 1", config.limit)
+	}
+
+	var runners []*OpenCLRunner
+	var free = func() {
+		for _, runner := range runners {
+			runner.Free()
+		}
+	}
+	for _, device := range devices {
+		runner, err := device.InitRunner(config.runnerOptions...)
+		if err != nil {
+			free()
+			return nil, fmt.Errorf("NewRunnerPool Err: device %q: %w", device.Name, err)
+		}
+		runners = append(runners, runner)
+		for _, program := range programs {
+			if err = program.compile(runner); err != nil {
+				free()
+				return nil, fmt.Errorf("NewRunnerPool Err: device %q: %w", device.Name, err)
+			}
+		}
+	}
+	return newRunnerPool(runners, &config), nil
+}
+
+func newRunnerPool(runners []*OpenCLRunner, config *poolConfig) *RunnerPool {
+	var pool = &RunnerPool{
+		runners: make(map[*OpenCLRunner]*poolEntry),
+		balance: config.balance,
+		changed: make(chan struct{}),
+	}
+	for _, runner := range runners {
+		var entry = &poolEntry{runner: runner, score: config.score(runner.Device), limit: config.limit}
+		pool.entries = append(pool.entries, entry)
+		pool.runners[runner] = entry
+	}
+	sort.SliceStable(pool.entries, func(i, j int) bool { return pool.entries[i].score > pool.entries[j].score })
+	return pool
+}
+
+// Acquire returns a runner once a device is below its limit, chosen by the balance strategy of the pool.
+// It returns ctx.Err() if ctx is done first, or ErrPoolClosed if the pool is closed.
+// The runner must be given back with Release.
+func (pool *RunnerPool) Acquire(ctx context.Context) (*OpenCLRunner, error) {
+	for {
+		pool.mu.Lock()
+		if pool.closed {
+			pool.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if entry := pool.pick(); entry != nil {
+			entry.inUse++
+			entry.acquired++
+			pool.mu.Unlock()
+			return entry.runner, nil
+		}
+		var changed = pool.changed
+		pool.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// pick returns the entry to acquire, or nil if all devices are at their limit.
+func (pool *RunnerPool) pick() *poolEntry {
+	var best *poolEntry
+	for _, entry := range pool.entries {
+		if entry.inUse >= entry.limit {
+			continue
+		}
+		if best == nil {
+			best = entry
+			if pool.balance == BalanceScore {
+				break
+			}
+			continue
+		}
+		// entries are sorted by score, so the first of equally loaded devices wins
+		if entry.inUse*best.limit < best.inUse*entry.limit {
+			best = entry
+		}
+	}
+	return best
+}
+
+// Release gives back a runner returned by Acquire.
+func (pool *RunnerPool) Release(runner *OpenCLRunner) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	var entry, ok = pool.runners[runner]
+	if !ok {
+		return fmt.Errorf("RunnerPool.Release Err: runner does not belong to the pool")
+	}
+	if entry.inUse == 0 {
+		return fmt.Errorf("RunnerPool.Release Err: runner of device %q is not acquired", runner.Device.Name)
+	}
+	entry.inUse--
+	pool.notify()
+	return nil
+}
+
+// notify wakes up the goroutines waiting in Acquire or Close. pool.mu must be held.
+func (pool *RunnerPool) notify() {
+	close(pool.changed)
+	pool.changed = make(chan struct{})
+}
+
+// Stats returns the use of each device, best scored first.
+func (pool *RunnerPool) Stats() []PoolStats {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	var stats []PoolStats
+	for _, entry := range pool.entries {
+		stats = append(stats, PoolStats{Device: entry.runner.Device, InUse: entry.inUse, Limit: entry.limit, Acquired: entry.acquired})
+	}
+	return stats
+}
+
+// Close stops handing out runners, waits until all acquired runners are released and frees them.
+// Goroutines waiting in Acquire get ErrPoolClosed.
+// If ctx is done before all runners are released, Close returns ctx.Err() without freeing anything;
+// it can be called again to keep waiting.
+func (pool *RunnerPool) Close(ctx context.Context) error {
+	pool.mu.Lock()
+	if !pool.closed {
+		pool.closed = true
+		pool.notify()
+	}
+	for {
+		if pool.freed {
+			pool.mu.Unlock()
+			return nil
+		}
+		if pool.drained() {
+			break
+		}
+		var changed = pool.changed
+		pool.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+		pool.mu.Lock()
+	}
+	pool.freed = true
+	pool.mu.Unlock()
+
+	var errs []error
+	for _, entry := range pool.entries {
+		if err := entry.runner.Free(); err != nil {
+			errs = append(errs, fmt.Errorf("device %q: %w", entry.runner.Device.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (pool *RunnerPool) drained() bool {
+	for _, entry := range pool.entries {
+		if entry.inUse > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package opencl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestPool(limit int, balance Balance, names ...string) *RunnerPool {
+	var runners []*OpenCLRunner
+	for i, name := range names {
+		runners = append(runners, &OpenCLRunner{Device: &OpenCLDevice{Name: name, Max_compute_units: uint32(len(names) - i), Max_clock_frequency: 1}})
+	}
+	return newRunnerPool(runners, &poolConfig{limit: limit, balance: balance, score: ComputeScore})
+}
+
+func TestRunnerPoolBalance(t *testing.T) {
+	var tests = []struct {
+		balance Balance
+		want    []string
+	}{
+		{BalanceQueueDepth, []string{"fast", "slow", "fast", "slow"}},
+		{BalanceScore, []string{"fast", "fast", "slow", "slow"}},
+	}
+	for _, test := range tests {
+		var pool = newTestPool(2, test.balance, "fast", "slow")
+		var got []string
+		for range test.want {
+			runner, err := pool.Acquire(context.Background())
+			if err != nil {
+				t.Fatal("Acquire err:", err)
+			}
+			got = append(got, runner.Device.Name)
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("balance %d: acquired %q, want %q", test.balance, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRunnerPoolLimit(t *testing.T) {
+	var pool = newTestPool(1, BalanceQueueDepth, "device")
+	runner, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal("Acquire err:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = pool.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire above the limit = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	var acquired = make(chan *OpenCLRunner)
+	go func() {
+		runner, _ := pool.Acquire(context.Background())
+		acquired <- runner
+	}()
+	if err = pool.Release(runner); err != nil {
+		t.Fatal("Release err:", err)
+	}
+	if got := <-acquired; got != runner {
+		t.Errorf("Acquire after Release = %v, want %v", got, runner)
+	}
+	if err = pool.Release(runner); err != nil {
+		t.Fatal("Release err:", err)
+	}
+	if err = pool.Release(runner); err == nil {
+		t.Error("Release of a released runner succeeded")
+	}
+	if err = pool.Release(&OpenCLRunner{}); err == nil {
+		t.Error("Release of a foreign runner succeeded")
+	}
+	if stats := pool.Stats(); stats[0].InUse != 0 || stats[0].Acquired != 2 {
+		t.Errorf("Stats = %+v", stats)
+	}
+}
+
+func TestRunnerPoolClose(t *testing.T) {
+	var pool = newTestPool(4, BalanceQueueDepth, "a", "b")
+	var runners []*OpenCLRunner
+	for i := 0; i < 8; i++ {
+		runner, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Fatal("Acquire err:", err)
+		}
+		runners = append(runners, runner)
+	}
+	var waiting = make(chan error)
+	go func() {
+		_, err := pool.Acquire(context.Background())
+		waiting <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close with acquired runners = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-waiting; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("waiting Acquire = %v, want %v", err, ErrPoolClosed)
+	}
+
+	var wg sync.WaitGroup
+	for _, runner := range runners {
+		wg.Add(1)
+		go func(runner *OpenCLRunner) {
+			defer wg.Done()
+			if err := pool.Release(runner); err != nil {
+				t.Error("Release err:", err)
+			}
+		}(runner)
+	}
+	if err := pool.Close(context.Background()); err != nil {
+		t.Fatal("Close err:", err)
+	}
+	wg.Wait()
+	if _, err := pool.Acquire(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Acquire after Close = %v, want %v", err, ErrPoolClosed)
+	}
+	if err := pool.Close(context.Background()); err != nil {
+		t.Error("second Close err:", err)
+	}
+}
+
+// TestRunnerPool tests running a kernel through a pool of all devices.
+func TestRunnerPool(t *testing.T) {
+	info, _ := Info()
+	var devices = info.SelectDevices(DeviceFilter{})
+	if len(devices) < 1 {
+		t.Skipf("No OpenCL Devices")
+	}
+
+	pool, err := NewRunnerPool(devices, []ProgramSource{{
+		Sources: []string{`__kernel void square(__global int* v) { int i = get_global_id(0); v[i] *= v[i]; }`},
+	}}, WithDeviceLimit(2))
+	if err != nil {
+		t.Fatal("NewRunnerPool err:", err)
+	}
+	defer pool.Close(context.Background())
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner, err := pool.Acquire(context.Background())
+			if err != nil {
+				t.Error("Acquire err:", err)
+				return
+			}
+			defer pool.Release(runner)
+
+			input := []int32{1, 2, 3, 4}
+			buf, err := CreateBuffer(runner, READ_WRITE|COPY_HOST_PTR, input)
+			if err != nil {
+				t.Error("CreateBuffer err:", err)
+				return
+			}
+			defer runner.ReleaseBuffer(buf)
+			if err = runner.RunKernel("square", 1, nil, []uint64{4}, nil, []KernelParam{BufferParam(buf)}, true); err != nil {
+				t.Error("RunKernel err:", err)
+				return
+			}
+			result := make([]int32, 4)
+			if err = ReadBuffer(runner, 0, buf, result); err != nil {
+				t.Error("ReadBuffer err:", err)
+			} else if result[3] != 16 {
+				t.Error("result error:", result)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,112 @@
+package opencl
+
+import (
+	"sort"
+	"time"
+)
+
+// Profile holds the device timestamps of a command.
+// The values are device timer readings in nanoseconds and only meaningful relative to each other.
+type Profile struct {
+	Queued    time.Duration
+	Submitted time.Duration
+	Started   time.Duration
+	Ended     time.Duration
+}
+
+// Duration returns the execution time of the command on the device.
+func (p Profile) Duration() time.Duration {
+	return p.Ended - p.Started
+}
+
+// Latency returns the time from enqueueing the command until it completed.
+func (p Profile) Latency() time.Duration {
+	return p.Ended - p.Queued
+}
+
+// Profile returns the device timestamps of a completed command.
+// The runner must have been created with WithProfiling, otherwise ErrProfilingInfoNotAvailable is returned.
+func (e *Event) Profile() (Profile, error) {
+	if e.event == nil {
+		return Profile{}, codeError("clGetEventProfilingInfo", ErrInvalidEvent)
+	}
+	return e.event.Profile()
+}
+
+// ProfileStats aggregates the execution times of the commands with the same name.
+// Kernels are named after the kernel, transfers are named "ReadBuffer" and "WriteBuffer".
+type ProfileStats struct {
+	Name  string
+	Count int
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+}
+
+func (runner *OpenCLRunner) recordProfile(name string, duration time.Duration) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if runner.profileStats == nil {
+		runner.profileStats = make(map[string]*ProfileStats)
+	}
+	var stats, ok = runner.profileStats[name]
+	if !ok {
+		stats = &ProfileStats{Name: name, Min: duration, Max: duration}
+		runner.profileStats[name] = stats
+	}
+	stats.Count++
+	stats.Total += duration
+	stats.Min = min(stats.Min, duration)
+	stats.Max = max(stats.Max, duration)
+	stats.Mean = stats.Total / time.Duration(stats.Count)
+}
+
+// ProfilingReport returns the execution time statistics of all completed commands by name.
+// Commands enqueued without returning an event are included once they have completed.
+func (runner *OpenCLRunner) ProfilingReport() []ProfileStats {
+	runner.collectPendingEvents()
+
+	runner.mu.Lock()
+	var report []ProfileStats
+	for _, stats := range runner.profileStats {
+		report = append(report, *stats)
+	}
+	runner.mu.Unlock()
+	sort.Slice(report, func(i, j int) bool { return report[i].Name < report[j].Name })
+	return report
+}
+
+// ResetProfiling discards the collected statistics.
+func (runner *OpenCLRunner) ResetProfiling() {
+	runner.collectPendingEvents()
+	runner.mu.Lock()
+	runner.profileStats = nil
+	runner.mu.Unlock()
+}
+
+// collectPendingEvents records and releases the completed events the runner keeps for profiling.
+// Events are released without holding runner.mu, since they record their profile on release.
+func (runner *OpenCLRunner) collectPendingEvents() {
+	runner.mu.Lock()
+	var events = runner.pendingEvents
+	runner.pendingEvents = nil
+	runner.mu.Unlock()
+
+	var pending []*Event
+	for _, e := range events {
+		if status, err := e.Status(); err == nil && status != Complete {
+			pending = append(pending, e)
+			continue
+		}
+		e.Release()
+	}
+
+	runner.mu.Lock()
+	runner.pendingEvents = append(pending, runner.pendingEvents...)
+	runner.pendingLimit = max(minPendingLimit, 2*len(runner.pendingEvents))
+	runner.mu.Unlock()
+}
+
+// minPendingLimit is the least number of pending events at which enqueued collects the completed ones.
+const minPendingLimit = 64
@@ -0,0 +1,196 @@
+package opencl
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// Program represents a compiled OpenCL program and the kernels created from it.
+type Program struct {
+	program BackendProgram
+	Kernels map[string]*Kernel
+	Logs    []BuildLog // compiler output of a successful build, e.g. warnings
+	id      uint64     // identifies the program in traces
+	handle
+}
+
+// BuildProgram compiles OpenCL kernels from the provided source code into a new Program for all devices of the runner.
+// The program is not added to the runner; use AddProgram to make its kernels available to RunKernel.
+// If the build fails, the returned error is a *BuildError carrying the compiler log.
+// If runner.Cache is set, a cached program binary is used when available, falling back
+// to a source build when the driver rejects it.
+func (runner *OpenCLRunner) BuildProgram(codeSourceList []string, kernelNameList []string, options string) (*Program, error) {
+	return runner.tracedBuildProgram(codeSourceList, kernelNameList, false, options)
+}
+
+// tracedBuildProgram is BuildProgram, creating every kernel of the program if all is set.
+func (runner *OpenCLRunner) tracedBuildProgram(codeSourceList []string, kernelNameList []string, all bool, options string) (*Program, error) {
+	var start = time.Now()
+	program, err := runner.buildProgram(codeSourceList, kernelNameList, all, options)
+	if all && program != nil {
+		kernelNameList = program.kernelNameList()
+	}
+	if runner.timeline != nil {
+		runner.timeline.call("BuildProgram", start, map[string]any{"kernels": kernelNameList, "options": options})
+	}
+	if runner.trace != nil {
+		var record = TraceRecord{Op: TraceBuildProgram, Sources: codeSourceList, Kernels: kernelNameList, Options: options}
+		if program != nil {
+			program.id = runner.trace.id()
+			record.Program = program.id
+			for _, kernel := range program.Kernels {
+				kernel.trace, kernel.programID = runner.trace, program.id
+			}
+		}
+		runner.trace.record(start, &record, err)
+	}
+	return program, err
+}
+
+func (runner *OpenCLRunner) buildProgram(codeSourceList []string, kernelNameList []string, all bool, options string) (*Program, error) {
+	if len(codeSourceList) == 0 {
+		return nil, fmt.Errorf("clCreateProgramWithSource Err: source is empty")
+	}
+
+	program, err := runner.context.BuildProgram(codeSourceList, options, runner.Cache)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = &Program{program: program, Kernels: make(map[string]*Kernel)}
+	result.obj = runner.tracker.track("program", "", "clReleaseProgram", false, program.Release)
+	for _, device := range runner.Devices {
+		if log := program.BuildLog(device); log.Log != "" {
+			result.Logs = append(result.Logs, log)
+		}
+	}
+
+	if all {
+		kernelNames, err := program.KernelNames()
+		if err != nil {
+			result.Release()
+			return nil, err
+		}
+		kernelNameList = kernelNames
+	}
+
+	// clCreateKernel
+	for _, kernelName := range kernelNameList {
+		kernel, err := newKernel(runner.tracker, program, kernelName)
+		if err != nil {
+			result.Release()
+			return nil, err
+		}
+		result.Kernels[kernelName] = kernel
+	}
+
+	if runner.tracker.finalizers {
+		runtime.SetFinalizer(result, (*Program).finalize)
+	}
+	return result, nil
+}
+
+// KernelNames returns the names of all kernels defined by the program, including the ones not in Kernels.
+func (program *Program) KernelNames() ([]string, error) {
+	return program.program.KernelNames()
+}
+
+// kernelNameList returns the sorted names of the kernels created from the program.
+func (program *Program) kernelNameList() []string {
+	var names = make([]string, 0, len(program.Kernels))
+	for name := range program.Kernels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Release releases the kernels and the program. Calling Release more than once has no effect.
+// The program must not be in use by a runner.
+func (program *Program) Release() error {
+	var err error
+	for kernelName, kernel := range program.Kernels {
+		if err2 := kernel.Release(); err2 != nil && err == nil {
+			err = err2
+		}
+		delete(program.Kernels, kernelName)
+	}
+	if err != nil {
+		return err
+	}
+	return program.releaseHandle()
+}
+
+// finalize releases a program that was garbage collected without Release.
+func (program *Program) finalize() {
+	if program.collected() {
+		program.Release()
+	}
+}
+
+// AddProgram makes the kernels of the program available to the runner.
+// It fails without adding anything if a kernel name is already provided by another program of the runner.
+// The runner releases the program on Free unless it is removed with RemoveProgram first.
+func (runner *OpenCLRunner) AddProgram(program *Program) (err error) {
+	if runner.trace != nil {
+		defer func(start time.Time) {
+			runner.trace.record(start, &TraceRecord{Op: TraceAddProgram, Program: program.id}, err)
+		}(time.Now())
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	for _, p := range runner.Programs {
+		if p == program {
+			return fmt.Errorf("AddProgram Err: program is already added")
+		}
+	}
+	for kernelName := range program.Kernels {
+		if _, ok := runner.Kernels[kernelName]; ok {
+			return fmt.Errorf("AddProgram Err: kernel %q is already defined by another program", kernelName)
+		}
+	}
+
+	if runner.Kernels == nil {
+		runner.Kernels = make(map[string]*Kernel)
+	}
+	for kernelName, kernel := range program.Kernels {
+		runner.Kernels[kernelName] = kernel
+	}
+	runner.Programs = append(runner.Programs, program)
+	program.obj.setOwned(true)
+	return nil
+}
+
+// RemoveProgram removes the program and its kernels from the runner without releasing it.
+func (runner *OpenCLRunner) RemoveProgram(program *Program) (err error) {
+	if runner.trace != nil {
+		defer func(start time.Time) {
+			runner.trace.record(start, &TraceRecord{Op: TraceRemoveProgram, Program: program.id}, err)
+		}(time.Now())
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	for i, p := range runner.Programs {
+		if p == program {
+			for kernelName := range program.Kernels {
+				delete(runner.Kernels, kernelName)
+			}
+			runner.Programs = append(runner.Programs[:i], runner.Programs[i+1:]...)
+			program.obj.setOwned(false)
+			return nil
+		}
+	}
+	return fmt.Errorf("RemoveProgram Err: program is not added to the runner")
+}
+
+// kernel looks up a kernel by name across all programs of the runner.
+func (runner *OpenCLRunner) kernel(fn string, kernelName string) (*Kernel, error) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if kernel, ok := runner.Kernels[kernelName]; ok {
+		return kernel, nil
+	}
+	return nil, &Error{Code: ErrInvalidKernelName, Name: ErrInvalidKernelName.Name(), Func: fn, Kernel: kernelName}
+}
@@ -0,0 +1,297 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// CacheEviction selects which entries a ProgramCache removes first when it exceeds its size limit.
+type CacheEviction int
+
+const (
+	// EvictLeastRecentlyUsed removes the entries that were loaded or stored least recently.
+	EvictLeastRecentlyUsed CacheEviction = iota
+	// EvictOldest removes the entries that were stored first, regardless of how often they are loaded.
+	EvictOldest
+)
+
+// ProgramCache is an on-disk cache of compiled program binaries.
+// Assign it to OpenCLRunner.Cache to let CompileKernels skip clBuildProgram from source
+// when a binary for the same source, options and device is available.
+type ProgramCache struct {
+	Dir      string
+	MaxSize  int64 // total size of the cached binaries in bytes, 0 means unlimited
+	Eviction CacheEviction
+}
+
+const defaultProgramCacheSize = 256 << 20
+
+// NewProgramCache returns a ProgramCache storing binaries in dir with a 256 MiB size limit.
+// If dir is empty, a go-opencl directory under os.UserCacheDir is used.
+func NewProgramCache(dir string) (*ProgramCache, error) {
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(userCacheDir, "go-opencl", "programs")
+	}
+	return &ProgramCache{Dir: dir, MaxSize: defaultProgramCacheSize, Eviction: EvictLeastRecentlyUsed}, nil
+}
+
+// programCacheKey identifies a program binary by everything that affects the compiler output.
+func programCacheKey(codeSourceList []string, options string, device *OpenCLDevice, platformVersion string) string {
+	var hash = sha256.New()
+	var write = func(s string) {
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(s)))
+		hash.Write(size[:])
+		hash.Write([]byte(s))
+	}
+	write("go-opencl program cache v1")
+	for _, codeSource := range codeSourceList {
+		write(codeSource)
+	}
+	write(options)
+	write(device.Name)
+	write(device.Vendor)
+	write(device.Version)
+	write(device.Driver_version)
+	write(platformVersion)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (cache *ProgramCache) path(key string) string {
+	return filepath.Join(cache.Dir, key+".bin")
+}
+
+// load returns the cached binary for key, or nil if there is none.
+func (cache *ProgramCache) load(key string) []byte {
+	var path = cache.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	if cache.Eviction == EvictLeastRecentlyUsed {
+		var now = time.Now()
+		os.Chtimes(path, now, now)
+	}
+	return data
+}
+
+// store writes the binary for key and evicts entries beyond MaxSize.
+func (cache *ProgramCache) store(key string, data []byte) error {
+	if err := os.MkdirAll(cache.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(cache.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), cache.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return cache.evict()
+}
+
+// remove deletes the binary for key, e.g. after the driver rejected it.
+func (cache *ProgramCache) remove(key string) {
+	os.Remove(cache.path(key))
+}
+
+func (cache *ProgramCache) evict() error {
+	if cache.MaxSize <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(cache.Dir)
+	if err != nil {
+		return err
+	}
+
+	var files []os.FileInfo
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".bin") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, file := range files {
+		if total <= cache.MaxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(cache.Dir, file.Name())); err == nil {
+			total -= file.Size()
+		}
+	}
+	return nil
+}
+
+// Clear removes all cached binaries.
+func (cache *ProgramCache) Clear() error {
+	entries, err := os.ReadDir(cache.Dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".bin") {
+			if err := os.Remove(filepath.Join(cache.Dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getPlatformVersion returns CL_PLATFORM_VERSION, or an empty string if it cannot be queried.
+func getPlatformVersion(platform_id C.cl_platform_id) string {
+	var version, _ = getPlatformInfoString(platform_id, C.CL_PLATFORM_VERSION)
+	return version
+}
+
+// programCacheKeys returns the cache keys of a program for each device of the context.
+func (ctx *clContext) programCacheKeys(codeSourceList []string, options string) []string {
+	var platformVersion = getPlatformVersion(ctx.devices[0].Platform_id.id)
+	var keys = make([]string, len(ctx.devices))
+	for i, device := range ctx.devices {
+		keys[i] = programCacheKey(codeSourceList, options, device, platformVersion)
+	}
+	return keys
+}
+
+// getProgramBinaries returns the binaries of a program in the order of the devices of the context.
+func (ctx *clContext) getProgramBinaries(program C.cl_program) ([][]byte, error) {
+	var numDevices C.cl_uint
+	var err = C.clGetProgramInfo(program, C.CL_PROGRAM_NUM_DEVICES, C.sizeof_cl_uint, unsafe.Pointer(&numDevices), nil)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clGetProgramInfo", err)
+	}
+	if numDevices == 0 {
+		return nil, nil
+	}
+
+	var device_ids = make([]C.cl_device_id, numDevices, numDevices)
+	err = C.clGetProgramInfo(program, C.CL_PROGRAM_DEVICES, C.size_t(unsafe.Sizeof(device_ids[0]))*C.size_t(numDevices),
+		unsafe.Pointer(&device_ids[0]), nil)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clGetProgramInfo", err)
+	}
+
+	var binarySizes = make([]C.size_t, numDevices, numDevices)
+	err = C.clGetProgramInfo(program, C.CL_PROGRAM_BINARY_SIZES, C.sizeof_size_t*C.size_t(numDevices),
+		unsafe.Pointer(&binarySizes[0]), nil)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clGetProgramInfo", err)
+	}
+
+	// The binaries are written through a pointer array, so they have to live in C memory.
+	var binary_ptrs = make([]*C.uchar, numDevices, numDevices)
+	for i, binarySize := range binarySizes {
+		if binarySize == 0 {
+			return nil, nil
+		}
+		binary_ptrs[i] = (*C.uchar)(C.malloc(binarySize))
+		defer C.free(unsafe.Pointer(binary_ptrs[i]))
+	}
+	err = C.clGetProgramInfo(program, C.CL_PROGRAM_BINARIES, C.size_t(unsafe.Sizeof(binary_ptrs[0]))*C.size_t(numDevices),
+		unsafe.Pointer(&binary_ptrs[0]), nil)
+	if err != C.CL_SUCCESS {
+		return nil, clError("clGetProgramInfo", err)
+	}
+
+	var binaries = make([][]byte, len(ctx.deviceIDs))
+	for i, device_id := range ctx.deviceIDs {
+		for j := range device_ids {
+			if device_ids[j] == device_id {
+				binaries[i] = C.GoBytes(unsafe.Pointer(binary_ptrs[j]), C.int(binarySizes[j]))
+			}
+		}
+		if binaries[i] == nil {
+			return nil, nil
+		}
+	}
+	return binaries, nil
+}
+
+// loadCachedProgram creates and builds a program from the cached binaries for all devices of the context.
+// It returns nil if a binary is missing or unusable; rejected binaries are removed from the cache.
+func (ctx *clContext) loadCachedProgram(cache *ProgramCache, keys []string, cl_options *C.char) C.cl_program {
+	var numDevices = len(ctx.deviceIDs)
+	var binary_ptrs = make([]*C.uchar, numDevices, numDevices)
+	var binarySizes = make([]C.size_t, numDevices, numDevices)
+	var binaryStatus = make([]C.cl_int, numDevices, numDevices)
+	for i, key := range keys {
+		var data = cache.load(key)
+		if data == nil {
+			return nil
+		}
+		binary_ptrs[i] = (*C.uchar)(C.CBytes(data))
+		defer C.free(unsafe.Pointer(binary_ptrs[i]))
+		binarySizes[i] = C.size_t(len(data))
+	}
+
+	var removeAll = func() {
+		for _, key := range keys {
+			cache.remove(key)
+		}
+	}
+
+	var err C.cl_int
+	var program = C.clCreateProgramWithBinary(ctx.context, C.cl_uint(numDevices), &ctx.deviceIDs[0], &binarySizes[0],
+		&binary_ptrs[0], &binaryStatus[0], &err)
+	if err != C.CL_SUCCESS {
+		if program != nil {
+			C.clReleaseProgram(program)
+		}
+		removeAll()
+		return nil
+	}
+
+	err = C.clBuildProgram(program, C.cl_uint(numDevices), &ctx.deviceIDs[0], cl_options, nil, nil)
+	if err != C.CL_SUCCESS {
+		C.clReleaseProgram(program)
+		removeAll()
+		return nil
+	}
+	return program
+}
+
+// storeProgramBinaries saves the binaries of a program built from source.
+// The cache is best effort, so failures are ignored.
+func (ctx *clContext) storeProgramBinaries(cache *ProgramCache, keys []string, program C.cl_program) {
+	binaries, err := ctx.getProgramBinaries(program)
+	if err != nil || binaries == nil {
+		return
+	}
+	for i, key := range keys {
+		cache.store(key, binaries[i])
+	}
+}
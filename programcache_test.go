@@ -0,0 +1,64 @@
+package opencl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProgramCacheKey(t *testing.T) {
+	device := &OpenCLDevice{Name: "gpu", Vendor: "vendor", Version: "OpenCL 3.0", Driver_version: "1.0"}
+	key := programCacheKey([]string{"a", "b"}, "-O2", device, "OpenCL 3.0")
+
+	if key != programCacheKey([]string{"a", "b"}, "-O2", device, "OpenCL 3.0") {
+		t.Fatal("key is not stable")
+	}
+	if key == programCacheKey([]string{"ab"}, "-O2", device, "OpenCL 3.0") {
+		t.Fatal("key ignores source boundaries")
+	}
+	if key == programCacheKey([]string{"a", "b"}, "", device, "OpenCL 3.0") {
+		t.Fatal("key ignores options")
+	}
+	other := *device
+	other.Driver_version = "2.0"
+	if key == programCacheKey([]string{"a", "b"}, "-O2", &other, "OpenCL 3.0") {
+		t.Fatal("key ignores the driver version")
+	}
+}
+
+func TestProgramCacheEviction(t *testing.T) {
+	cache, _ := NewProgramCache(t.TempDir())
+	cache.MaxSize = 8
+
+	if err := cache.store("a", []byte("1234")); err != nil {
+		t.Fatal("store err:", err)
+	}
+	if err := cache.store("b", []byte("5678")); err != nil {
+		t.Fatal("store err:", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(cache.path("a"), past, past)
+	os.Chtimes(cache.path("b"), past.Add(time.Minute), past.Add(time.Minute))
+
+	// loading "a" makes "b" the least recently used entry
+	if data := cache.load("a"); string(data) != "1234" {
+		t.Fatal("load returned:", data)
+	}
+	if err := cache.store("c", []byte("9")); err != nil {
+		t.Fatal("store err:", err)
+	}
+	if cache.load("b") != nil {
+		t.Fatal("b was not evicted")
+	}
+	if cache.load("a") == nil || cache.load("c") == nil {
+		t.Fatal("a or c was evicted")
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatal("Clear err:", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(cache.Dir, "*")); len(matches) != 0 {
+		t.Fatal("Clear left files:", matches)
+	}
+}
@@ -0,0 +1,178 @@
+package opencl
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Queue represents one of the command queues of an OpenCLRunner.
+type Queue struct {
+	queue  BackendQueue
+	runner *OpenCLRunner
+
+	Device *OpenCLDevice
+	Index  int
+}
+
+// Enqueuer is the target of the buffer transfer functions:
+// an *OpenCLRunner, which uses its first command queue, or one of its *Queue.
+type Enqueuer interface {
+	commandQueue() *Queue
+}
+
+func (runner *OpenCLRunner) commandQueue() *Queue {
+	return runner.Queues[0]
+}
+
+func (queue *Queue) commandQueue() *Queue {
+	return queue
+}
+
+// Queue returns the command queue with the given index.
+func (runner *OpenCLRunner) Queue(index int) (*Queue, error) {
+	if index < 0 || index >= len(runner.Queues) {
+		return nil, fmt.Errorf("Queue Err: index %d out of range, the runner has %d queues", index, len(runner.Queues))
+	}
+	return runner.Queues[index], nil
+}
+
+// DeviceQueue returns the first command queue of the device.
+func (runner *OpenCLRunner) DeviceQueue(device *OpenCLDevice) (*Queue, error) {
+	var queues = runner.DeviceQueues(device)
+	if len(queues) == 0 {
+		return nil, fmt.Errorf("DeviceQueue Err: device %q is not used by the runner", device.Name)
+	}
+	return queues[0], nil
+}
+
+// DeviceQueues returns the command queues of the device.
+func (runner *OpenCLRunner) DeviceQueues(device *OpenCLDevice) []*Queue {
+	var queues []*Queue
+	for _, queue := range runner.Queues {
+		if queue.Device == device || queue.Device.Device_id == device.Device_id {
+			queues = append(queues, queue)
+		}
+	}
+	return queues
+}
+
+// RunKernel runs an OpenCL kernel on this queue with the specified work dimensions, work sizes, and arguments.
+// If wait is true, RunKernel blocks until the kernel has completed.
+func (queue *Queue) RunKernel(kernelName string, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam, wait bool) error {
+	return queue.runKernel(context.Background(), kernelName, work_dim, global_work_offset, global_work_size, local_work_size, args, wait)
+}
+
+// RunKernelCtx runs an OpenCL kernel on this queue and waits for it like RunKernel, but returns ctx.Err()
+// if ctx is done before the kernel has completed.
+//
+// The kernel is not cancelled: it stays enqueued and may still run, and the commands enqueued after it on the queue
+// run after it, so Finish blocks until it has completed. Its event is released in the background once it has completed.
+func (queue *Queue) RunKernelCtx(ctx context.Context, kernelName string, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam) error {
+	return queue.runKernel(ctx, kernelName, work_dim, global_work_offset, global_work_size, local_work_size, args, true)
+}
+
+func (queue *Queue) runKernel(ctx context.Context, kernelName string, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam, wait bool) error {
+	var start = time.Now()
+	var call = queue.runner.beforeHooks(ctx, Operation{Name: "RunKernel", Kernel: kernelName,
+		GlobalSize: global_work_size, LocalSize: local_work_size, Queue: queue.Index})
+	var err = queue.runKernelEvent(ctx, kernelName, work_dim, global_work_offset, global_work_size, local_work_size, args, wait)
+	call.after(err)
+	queue.kernelCall("RunKernel", kernelName, start, global_work_size, local_work_size)
+	return err
+}
+
+func (queue *Queue) runKernelEvent(ctx context.Context, kernelName string, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam, wait bool) error {
+	event, err := queue.enqueueKernel("RunKernel", kernelName, work_dim,
+		global_work_offset, global_work_size, local_work_size, args, nil, wait)
+	if err != nil || event == nil {
+		return err
+	}
+
+	if err = event.waitRelease(ctx); err != nil {
+		if clErr, ok := err.(*Error); ok {
+			clErr.Kernel = kernelName
+		}
+		return err
+	}
+	return nil
+}
+
+// EnqueueKernel enqueues an OpenCL kernel on this queue to run after the events in waitList and returns its event.
+func (queue *Queue) EnqueueKernel(kernelName string, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam,
+	waitList []*Event) (*Event, error) {
+	var start = time.Now()
+	var call = queue.runner.beforeHooks(context.Background(), Operation{Name: "EnqueueKernel", Kernel: kernelName,
+		GlobalSize: global_work_size, LocalSize: local_work_size, Queue: queue.Index})
+	event, err := queue.enqueueKernel("EnqueueKernel", kernelName, work_dim,
+		global_work_offset, global_work_size, local_work_size, args, waitList, true)
+	call.after(err)
+	queue.kernelCall("EnqueueKernel", kernelName, start, global_work_size, local_work_size)
+	return event, err
+}
+
+func (queue *Queue) enqueueKernel(fn string, kernelName string, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam,
+	waitList []*Event, withEvent bool) (*Event, error) {
+	var start = time.Now()
+	event, err := queue.enqueueKernelEvent(fn, kernelName, work_dim, global_work_offset, global_work_size, local_work_size,
+		args, waitList, withEvent)
+	if trace := queue.runner.trace; trace != nil {
+		trace.record(start, &TraceRecord{Op: TraceEnqueueKernel, Queue: queue.Index, Kernel: kernelName, WorkDim: work_dim,
+			GlobalOffset: global_work_offset, GlobalSize: global_work_size, LocalSize: local_work_size, Args: traceArgs(args)}, err)
+	}
+	return event, err
+}
+
+func (queue *Queue) enqueueKernelEvent(fn string, kernelName string, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam,
+	waitList []*Event, withEvent bool) (*Event, error) {
+	kernel, argErr := queue.runner.kernel(fn, kernelName)
+	if argErr != nil {
+		return nil, argErr
+	}
+	impl, done, argErr := kernel.acquire(args)
+	if argErr != nil {
+		return nil, argErr
+	}
+	defer done()
+
+	var start = time.Now()
+	event, err := queue.queue.EnqueueKernel(impl, work_dim, global_work_offset, global_work_size, local_work_size,
+		eventWaitList(waitList), withEvent || queue.runner.profiling)
+	if err != nil {
+		return nil, withKernel(err, kernelName)
+	}
+	var cmd = queue.kernelCommand(kernelName, start, global_work_size, local_work_size)
+	return queue.runner.enqueued(event, nil, cmd, false, withEvent), nil
+}
+
+// Flush issues all previously queued commands to the device.
+func (queue *Queue) Flush() error {
+	return queue.queue.Flush()
+}
+
+// Finish blocks until all previously queued commands have completed.
+func (queue *Queue) Finish() error {
+	var start = time.Now()
+	var err = queue.queue.Finish()
+	if queue.runner.timeline != nil {
+		queue.runner.timeline.call("Finish", start, map[string]any{"queue": queue.Index})
+	}
+	return err
+}
+
+// Finish blocks until the commands of all queues have completed.
+func (runner *OpenCLRunner) Finish() error {
+	for _, queue := range runner.Queues {
+		if err := queue.Finish(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
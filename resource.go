@@ -0,0 +1,246 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LeakDetectionEnv enables leak detection for all runners when set to a non-empty value,
+// as if they were created with WithLeakDetection(nil).
+const LeakDetectionEnv = "GO_OPENCL_LEAKS"
+
+// Leak describes an OpenCL object that was still alive when its runner was freed,
+// or whose handle was garbage collected without being released.
+type Leak struct {
+	Kind      string // "context", "queue", "buffer", "program", "kernel" or "event"
+	Name      string // the kernel or command name, if any
+	Stack     string // where the object was created
+	Collected bool   // the handle was garbage collected without Release or Free
+}
+
+func (leak Leak) String() string {
+	var s = leak.Kind
+	if leak.Name != "" {
+		s += " " + strconv.Quote(leak.Name)
+	}
+	if leak.Collected {
+		s += " garbage collected without release"
+	}
+	return s + ", created at:\n" + leak.Stack
+}
+
+// object is an OpenCL object shared by one or more handles.
+// It is released with its last handle, or when its runner is freed.
+type object struct {
+	kind    string
+	name    string
+	fn      string // the clRelease function, for errors
+	release func() ErrorCode
+	refs    int
+	owned   bool // released by the runner without being a leak, e.g. queues and added programs
+	seq     uint64
+	stack   []uintptr
+	tracker *tracker
+}
+
+// tracker keeps the live objects of a runner so that Free can release them.
+type tracker struct {
+	mu      sync.Mutex
+	objects map[*object]struct{}
+	seq     uint64
+	memory  atomic.Int64 // the size of the live buffers
+
+	finalizers    bool
+	leakDetection bool
+	report        func([]Leak)
+}
+
+func newTracker(config *runnerConfig) *tracker {
+	return &tracker{
+		objects:       make(map[*object]struct{}),
+		finalizers:    config.finalizers,
+		leakDetection: config.leakDetection,
+		report:        config.leakReport,
+	}
+}
+
+// track registers a new object with one reference.
+func (t *tracker) track(kind, name, fn string, owned bool, release func() ErrorCode) *object {
+	var obj = &object{kind: kind, name: name, fn: fn, release: release, refs: 1, owned: owned, tracker: t}
+	if t.leakDetection {
+		var pcs = make([]uintptr, 32)
+		obj.stack = pcs[:runtime.Callers(3, pcs)]
+	}
+	t.mu.Lock()
+	t.seq++
+	obj.seq = t.seq
+	t.objects[obj] = struct{}{}
+	t.mu.Unlock()
+	return obj
+}
+
+// retain adds a reference, unless the object has already been released.
+func (obj *object) retain() bool {
+	obj.tracker.mu.Lock()
+	defer obj.tracker.mu.Unlock()
+	if obj.refs == 0 {
+		return false
+	}
+	obj.refs++
+	return true
+}
+
+// releaseRef drops a reference and releases the OpenCL object with the last one.
+func (obj *object) releaseRef() error {
+	var t = obj.tracker
+	t.mu.Lock()
+	if obj.refs == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	obj.refs--
+	if obj.refs > 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	delete(t.objects, obj)
+	t.mu.Unlock()
+	return obj.destroy()
+}
+
+func (obj *object) destroy() error {
+	if err := obj.release(); err != C.CL_SUCCESS {
+		return clError(obj.fn, C.cl_int(err))
+	}
+	return nil
+}
+
+func (obj *object) alive() bool {
+	obj.tracker.mu.Lock()
+	defer obj.tracker.mu.Unlock()
+	return obj.refs > 0
+}
+
+func (obj *object) setOwned(owned bool) {
+	obj.tracker.mu.Lock()
+	obj.owned = owned
+	obj.tracker.mu.Unlock()
+}
+
+func (obj *object) leak(collected bool) Leak {
+	return Leak{Kind: obj.kind, Name: obj.name, Stack: formatStack(obj.stack), Collected: collected}
+}
+
+// releaseAll releases all live objects, newest first.
+// In leak detection mode, it returns the objects that should have been released by their user,
+// or all of them if the runner itself was garbage collected.
+func (t *tracker) releaseAll(collected bool) ([]Leak, error) {
+	t.mu.Lock()
+	var objects = make([]*object, 0, len(t.objects))
+	for obj := range t.objects {
+		objects = append(objects, obj)
+		obj.refs = 0
+	}
+	t.objects = make(map[*object]struct{})
+	t.mu.Unlock()
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].seq > objects[j].seq })
+	var leaks []Leak
+	var firstErr error
+	for _, obj := range objects {
+		if t.leakDetection && (collected || !obj.owned) {
+			leaks = append(leaks, obj.leak(collected))
+		}
+		if err := obj.destroy(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	// report in creation order
+	for i, j := 0, len(leaks)-1; i < j; i, j = i+1, j-1 {
+		leaks[i], leaks[j] = leaks[j], leaks[i]
+	}
+	return leaks, firstErr
+}
+
+// reportLeaks passes leaks to the WithLeakDetection callback, or logs them.
+func (t *tracker) reportLeaks(leaks []Leak) {
+	if len(leaks) == 0 {
+		return
+	}
+	if t.report != nil {
+		t.report(leaks)
+		return
+	}
+	for _, leak := range leaks {
+		log.Printf("opencl: leaked %s", leak)
+	}
+}
+
+func formatStack(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	var frames = runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// handle is one reference to an object. Releasing a handle more than once has no effect.
+type handle struct {
+	obj      *object
+	released atomic.Bool
+}
+
+func (h *handle) releaseHandle() error {
+	if h.obj == nil || !h.released.CompareAndSwap(false, true) {
+		return nil
+	}
+	return h.obj.releaseRef()
+}
+
+// finalize releases a handle that was garbage collected without Release.
+func (h *handle) finalize() {
+	if h.collected() {
+		h.releaseHandle()
+	}
+}
+
+// collected reports a handle that was garbage collected without Release if leak detection is enabled.
+// It returns false if there is nothing left to release.
+func (h *handle) collected() bool {
+	if h.obj == nil || h.released.Load() || !h.obj.alive() {
+		return false
+	}
+	if h.obj.tracker.leakDetection {
+		h.obj.tracker.reportLeaks([]Leak{h.obj.leak(true)})
+	}
+	return true
+}
+
+// runnerCleanup is only referenced by its runner. Unlike the runner, which is part of
+// reference cycles with its queues, it can carry a finalizer that runs when a runner
+// is garbage collected without Free.
+type runnerCleanup struct {
+	tracker *tracker
+}
+
+func (cleanup *runnerCleanup) finalize() {
+	leaks, _ := cleanup.tracker.releaseAll(true)
+	cleanup.tracker.reportLeaks(leaks)
+}
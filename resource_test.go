@@ -0,0 +1,153 @@
+package opencl
+
+import (
+	"errors"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeObjects tracks objects whose release functions record the order in which they were released.
+type fakeObjects struct {
+	released []string
+}
+
+func (f *fakeObjects) track(t *tracker, kind, name string, owned bool) *object {
+	return t.track(kind, name, "clRelease", owned, func() ErrorCode {
+		f.released = append(f.released, name)
+		return 0
+	})
+}
+
+func TestHandleRelease(t *testing.T) {
+	var fake fakeObjects
+	var tr = newTracker(&runnerConfig{})
+	var buffer = &Buffer{}
+	buffer.obj = fake.track(tr, "buffer", "a", false)
+
+	retained, err := buffer.Retain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = buffer.Release(); err != nil || len(fake.released) != 0 {
+		t.Fatalf("Release with a retained handle: err %v, released %q", err, fake.released)
+	}
+	if err = buffer.Release(); err != nil || len(fake.released) != 0 {
+		t.Fatalf("second Release: err %v, released %q", err, fake.released)
+	}
+	if err = retained.Release(); err != nil || !slices.Equal(fake.released, []string{"a"}) {
+		t.Fatalf("Release of the last handle: err %v, released %q", err, fake.released)
+	}
+	if _, err = buffer.Retain(); err == nil {
+		t.Error("Retain of a released buffer succeeded")
+	}
+	if len(tr.objects) != 0 {
+		t.Errorf("%d objects still tracked", len(tr.objects))
+	}
+}
+
+func TestTrackerReleaseAll(t *testing.T) {
+	var fake fakeObjects
+	var tr = newTracker(&runnerConfig{leakDetection: true})
+	fake.track(tr, "context", "context", true)
+	fake.track(tr, "buffer", "buffer", false)
+	var kernel = &Kernel{}
+	kernel.obj = fake.track(tr, "kernel", "kernel", true)
+	fake.track(tr, "event", "event", false)
+	kernel.Release()
+
+	leaks, err := tr.releaseAll(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"kernel", "event", "buffer", "context"}; !slices.Equal(fake.released, want) {
+		t.Errorf("released %q, want %q", fake.released, want)
+	}
+	var names []string
+	for _, leak := range leaks {
+		names = append(names, leak.Name)
+		if !strings.Contains(leak.Stack, "TestTrackerReleaseAll") {
+			t.Errorf("leak %s does not point to its creation", leak)
+		}
+	}
+	if want := []string{"buffer", "event"}; !slices.Equal(names, want) {
+		t.Errorf("leaks %q, want %q", names, want)
+	}
+
+	// handles released after Free have no effect
+	fake.released = nil
+	if err = kernel.Release(); err != nil || len(fake.released) != 0 {
+		t.Errorf("Release after releaseAll: err %v, released %q", err, fake.released)
+	}
+}
+
+func TestTrackerReleaseError(t *testing.T) {
+	var tr = newTracker(&runnerConfig{})
+	var buffer = &Buffer{}
+	buffer.obj = tr.track("buffer", "", "clReleaseMemObject", false, func() ErrorCode {
+		return ErrInvalidMemObject
+	})
+	var err = buffer.Release()
+	if !errors.Is(err, ErrInvalidMemObject) {
+		t.Errorf("Release = %v, want %v", err, ErrInvalidMemObject)
+	}
+}
+
+func TestHandleCollected(t *testing.T) {
+	var fake fakeObjects
+	var reported []Leak
+	var tr = newTracker(&runnerConfig{leakDetection: true, leakReport: func(leaks []Leak) {
+		reported = append(reported, leaks...)
+	}})
+	var program = &Program{Kernels: map[string]*Kernel{"k": {}}}
+	program.obj = fake.track(tr, "program", "program", false)
+	program.Kernels["k"].obj = fake.track(tr, "kernel", "k", true)
+
+	program.finalize()
+	if want := []string{"k", "program"}; !slices.Equal(fake.released, want) {
+		t.Errorf("released %q, want %q", fake.released, want)
+	}
+	if len(reported) != 1 || reported[0].Kind != "program" || !reported[0].Collected {
+		t.Errorf("reported %v, want the collected program", reported)
+	}
+
+	reported = nil
+	program.finalize()
+	if len(reported) != 0 {
+		t.Errorf("finalize of a released program reported %v", reported)
+	}
+}
+
+func TestRunnerCollected(t *testing.T) {
+	var mu sync.Mutex
+	var reported []Leak
+	func() {
+		runner, err := NewFakeBackend().InitRunner(WithFinalizers(), WithLeakDetection(func(leaks []Leak) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, leaks...)
+		}))
+		if err != nil {
+			t.Fatal("InitRunner err:", err)
+		}
+		if _, err = runner.CreateEmptyBuffer(READ_WRITE, 16); err != nil {
+			t.Fatal("CreateEmptyBuffer err:", err)
+		}
+	}()
+
+	// the runner is dropped without Free, so its buffer is reported once the runner is collected
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+		mu.Lock()
+		var found = slices.ContainsFunc(reported, func(leak Leak) bool { return leak.Kind == "buffer" })
+		mu.Unlock()
+		if found {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("the buffer of a collected runner was not reported, reported %v", reported)
+}
@@ -4,147 +4,303 @@ package opencl
 import "C"
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
 	"unsafe"
 )
 
 // Buffer represents an OpenCL buffer.
+// Buffers are released by Release, ReleaseBuffer or the runner's Free, whichever comes first.
 type Buffer struct {
-	buffer C.cl_mem
+	buffer BackendBuffer
+	size   int
+	id     uint64 // identifies the buffer in traces
+	handle
+}
+
+// Release releases the buffer. Calling Release more than once has no effect.
+// The memory object itself is released once all handles returned by Retain are released as well.
+func (buffer *Buffer) Release() error {
+	return buffer.releaseHandle()
+}
+
+// Retain returns a new handle of the buffer that keeps the memory object alive until it is released.
+func (buffer *Buffer) Retain() (*Buffer, error) {
+	if buffer.released.Load() || !buffer.obj.retain() {
+		return nil, withBuffer(codeError("Retain", ErrInvalidMemObject), buffer)
+	}
+	var retained = &Buffer{buffer: buffer.buffer, size: buffer.size, id: buffer.id}
+	retained.obj = buffer.obj
+	if buffer.obj.tracker.finalizers {
+		runtime.SetFinalizer(retained, (*Buffer).finalize)
+	}
+	return retained, nil
 }
 
 // OpenCLRunner represents an OpenCL runner.
+//
+// A runner may be used by several goroutines at once: kernels can be run, buffers created,
+// read and written, and programs added or removed concurrently. Free must not be called
+// while the runner is in use. The exported fields are only safe to read while no other
+// goroutine adds or removes programs or buffers; prefer the Kernel method to look up kernels.
 type OpenCLRunner struct {
-	Device       *OpenCLDevice
-	Context      C.cl_context
-	CommandQueue C.cl_command_queue
+	Device       *OpenCLDevice      // the first of Devices
+	Devices      []*OpenCLDevice    // the devices sharing Context
+	Context      C.cl_context       // nil unless the runner uses the OpenCL library
+	CommandQueue C.cl_command_queue // the command queue of Queues[0], nil unless the runner uses the OpenCL library
+	Queues       []*Queue
+
+	Programs []*Program
+	Kernels  map[string]*Kernel // kernels of all Programs by name
+	Buffers  []*Buffer          // buffers created by the runner and not released yet
+
+	// Cache, if set, stores and reuses program binaries across CompileKernels calls.
+	Cache *ProgramCache
+
+	mu            sync.Mutex // guards Programs, Kernels, Buffers, profileStats and pendingEvents
+	profiling     bool
+	profileStats  map[string]*ProfileStats
+	pendingEvents []*Event // events kept only for profiling
+	pendingLimit  int      // the number of pendingEvents at which completed ones are collected
+
+	releasing sync.RWMutex // held by Free while it releases all objects, see Event.releaseWhenComplete
+
+	context  BackendContext
+	trace    *tracer   // nil unless the runner was created WithTrace
+	timeline *timeline // nil unless the runner was created WithTimeline
+	hooks    []Hook
+
+	tracker *tracker
+	cleanup *runnerCleanup
+}
 
-	Program C.cl_program
-	Kernels map[string]C.cl_kernel
-	Buffers []*Buffer
+// RunnerOption configures the runner created by InitRunner.
+type RunnerOption func(*runnerConfig)
+
+type runnerConfig struct {
+	queueProperties C.cl_command_queue_properties
+	queueCount      int
+	contextCallback ContextCallback
+	finalizers      bool
+	leakDetection   bool
+	leakReport      func([]Leak)
+	backend         Backend
+	trace           io.Writer
+	traceContent    TraceContent
+	timeline        bool
+	hooks           []Hook
 }
 
-// InitRunner initializes an OpenCLRunner for the given OpenCLDevice.
-// It creates a context and a command queue.
-func (device *OpenCLDevice) InitRunner() (*OpenCLRunner, error) {
-	var runner = OpenCLRunner{Device: device}
+// WithProfiling creates the command queues with CL_QUEUE_PROFILING_ENABLE,
+// so that Event.Profile and OpenCLRunner.ProfilingReport are available.
+func WithProfiling() RunnerOption {
+	return func(config *runnerConfig) {
+		config.queueProperties |= C.CL_QUEUE_PROFILING_ENABLE
+	}
+}
 
-	// clCreateContext
-	var context_properties = [3]C.cl_context_properties{
-		C.CL_CONTEXT_PLATFORM,
-		C.cl_context_properties(uintptr(unsafe.Pointer(device.Platform_id))),
-		0,
+// WithOutOfOrder creates the command queues with CL_QUEUE_OUT_OF_ORDER_EXEC_MODE_ENABLE.
+// Commands may then run in any order, so dependencies must be expressed with event wait lists.
+func WithOutOfOrder() RunnerOption {
+	return func(config *runnerConfig) {
+		config.queueProperties |= C.CL_QUEUE_OUT_OF_ORDER_EXEC_MODE_ENABLE
 	}
-	var err C.cl_int
-	var context = C.clCreateContext(&context_properties[0], 1, &device.Device_id, nil, nil, &err)
-	if err != C.CL_SUCCESS {
-		return nil, fmt.Errorf("clCreateContext Err: %v", err)
+}
+
+// WithQueues sets the number of command queues to create per device, 1 by default.
+func WithQueues(count int) RunnerOption {
+	return func(config *runnerConfig) {
+		config.queueCount = count
 	}
+}
 
-	// clCreateCommandQueue
-	var commandQueueProperties C.cl_command_queue_properties = 0
-	var commandQueue = C.clCreateCommandQueue(context, device.Device_id, commandQueueProperties, &err)
-	if err != C.CL_SUCCESS {
-		C.clReleaseContext(context)
-		return nil, fmt.Errorf("clCreateCommandQueueErr: %v", err)
+// WithContextCallback registers a callback for the error notifications of the context,
+// e.g. to forward driver errors to a logger.
+func WithContextCallback(callback ContextCallback) RunnerOption {
+	return func(config *runnerConfig) {
+		config.contextCallback = callback
 	}
+}
 
-	runner.Context = context
-	runner.CommandQueue = commandQueue
+// WithFinalizers sets finalizers that release the OpenCL objects of the runner when their handles are garbage
+// collected without Release: the context, queues and buffers of a runner that was not freed, and
+// events and programs that were not released. Finalizers are a safety net, they may run late or not at all.
+func WithFinalizers() RunnerOption {
+	return func(config *runnerConfig) {
+		config.finalizers = true
+	}
+}
 
-	return &runner, nil
+// WithLeakDetection records where each buffer, program, kernel and event is created, and reports the ones
+// that are still alive when the runner is freed, or that are garbage collected without Release.
+// Leaks are passed to report, or logged if report is nil. report may be called from a finalizer goroutine.
+// Setting the GO_OPENCL_LEAKS environment variable enables leak detection for all runners.
+func WithLeakDetection(report func(leaks []Leak)) RunnerOption {
+	return func(config *runnerConfig) {
+		config.leakDetection = true
+		config.leakReport = report
+	}
 }
 
-// Free releases all resources associated with the OpenCLRunner.
-func (runner *OpenCLRunner) Free() error {
-	var err C.cl_int
+// InitRunner initializes an OpenCLRunner for the given OpenCLDevice.
+// It creates a context and one command queue, or as many as requested with WithQueues.
+func (device *OpenCLDevice) InitRunner(opts ...RunnerOption) (*OpenCLRunner, error) {
+	return initRunner([]*OpenCLDevice{device}, opts)
+}
 
-	if len(runner.Kernels) > 0 {
-		for _, kernel := range runner.Kernels {
-			err = C.clReleaseKernel(kernel)
+// InitRunner initializes an OpenCLRunner for several devices of the platform, or all of them if devices is empty.
+// The devices share one context, so programs are built for all of them and buffers can be used from any device.
+// Each device gets its own command queues, in the order of devices; use DeviceQueue to pick the device a kernel runs on.
+func (platform *OpenCLPlatform) InitRunner(devices []*OpenCLDevice, opts ...RunnerOption) (*OpenCLRunner, error) {
+	if len(devices) == 0 {
+		devices = platform.Devices
+	}
+	if len(devices) == 0 {
+		return nil, clError("InitRunner", C.CL_DEVICE_NOT_FOUND)
+	}
+	for _, device := range devices {
+		if device.Platform_id != platform.Platform_id {
+			return nil, fmt.Errorf("InitRunner Err: device %q does not belong to platform %q", device.Name, platform.Name)
 		}
 	}
+	return initRunner(devices, opts)
+}
 
-	if runner.Program != nil {
-		err = C.clReleaseProgram(runner.Program)
+func initRunner(devices []*OpenCLDevice, opts []RunnerOption) (*OpenCLRunner, error) {
+	var config = runnerConfig{queueCount: 1, leakDetection: os.Getenv(LeakDetectionEnv) != "", backend: clBackend{}}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.queueCount < 1 {
+		return nil, fmt.Errorf("InitRunner Err: invalid number of queues %d", config.queueCount)
 	}
 
-	if len(runner.Buffers) > 0 {
-		for _, buffer := range runner.Buffers {
-			err = C.clReleaseMemObject(buffer.buffer)
-		}
+	var runner = OpenCLRunner{Device: devices[0], Devices: devices, hooks: config.hooks}
+	runner.profiling = config.queueProperties&C.CL_QUEUE_PROFILING_ENABLE != 0
+	if config.timeline {
+		runner.timeline = &timeline{start: time.Now()}
 	}
 
-	err = C.clReleaseCommandQueue(runner.CommandQueue)
-	err = C.clReleaseContext(runner.Context)
+	context, err := config.backend.NewContext(devices, config.contextCallback)
+	if err != nil {
+		return nil, err
+	}
+	runner.context = context
+	if context, ok := context.(*clContext); ok {
+		runner.Context = context.context
+	}
+	runner.tracker = newTracker(&config)
+	runner.tracker.track("context", "", "clReleaseContext", true, context.Release)
+	if config.finalizers {
+		runner.cleanup = &runnerCleanup{tracker: runner.tracker}
+		runtime.SetFinalizer(runner.cleanup, (*runnerCleanup).finalize)
+	}
 
-	if err != C.CL_SUCCESS {
-		return fmt.Errorf("OpenCLRunner.Free cl_err: %v", err)
+	for _, device := range devices {
+		for i := 0; i < config.queueCount; i++ {
+			queue, err := context.NewQueue(device, config.queueProperties)
+			if err != nil {
+				runner.Free()
+				return nil, err
+			}
+			runner.tracker.track("queue", "", "clReleaseCommandQueue", true, queue.Release)
+			runner.Queues = append(runner.Queues, &Queue{queue: queue, runner: &runner, Device: device, Index: len(runner.Queues)})
+		}
+	}
+	if queue, ok := runner.Queues[0].queue.(*clQueue); ok {
+		runner.CommandQueue = queue.queue
+	}
+	if config.trace != nil {
+		if runner.trace, err = newTracer(config.trace, config.traceContent, &runner, config.queueCount); err != nil {
+			runner.Free()
+			return nil, err
+		}
 	}
 
-	return nil
+	return &runner, nil
 }
 
-// CompileKernels compiles OpenCL kernels from the provided source code.
-func (runner *OpenCLRunner) CompileKernels(codeSourceList []string, kernelNameList []string, options string) error {
-	var codes [](*C.char)
-	for _, codeSource := range codeSourceList {
-		code_src := C.CString(codeSource)
-		defer C.free(unsafe.Pointer(code_src))
-		codes = append(codes, code_src)
+// Free releases all resources associated with the OpenCLRunner, including the buffers, programs and events
+// that were not released yet. Handles of released objects may still be released afterwards, which has no effect.
+// Calling Free more than once has no effect.
+func (runner *OpenCLRunner) Free() error {
+	if runner.tracker == nil {
+		return nil
 	}
+	var call = runner.beforeHooks(context.Background(), Operation{Name: "Free"})
+	var err = runner.free()
+	call.after(err)
+	return err
+}
 
-	var err C.cl_int
-
-	// clCreateProgramWithSource
-	var program = C.clCreateProgramWithSource(runner.Context, C.cl_uint(len(codes)), &codes[0], nil, &err)
-	if err != C.CL_SUCCESS {
-		return fmt.Errorf("clCreateProgramWithSource Err: %v", err)
+func (runner *OpenCLRunner) free() error {
+	runner.mu.Lock()
+	var pendingEvents = runner.pendingEvents
+	runner.pendingEvents = nil
+	runner.mu.Unlock()
+	for _, e := range pendingEvents {
+		e.Release()
 	}
 
-	cl_options := C.CString(options)
-	defer C.free(unsafe.Pointer(cl_options))
-
-	// clBuildProgram
-	err = C.clBuildProgram(program, 1, &runner.Device.Device_id, cl_options, nil, nil)
-	if err != C.CL_SUCCESS {
-		var logSize C.size_t
-		var err2 = C.clGetProgramBuildInfo(program, runner.Device.Device_id, C.CL_PROGRAM_BUILD_LOG, 0, nil, &logSize)
-		if err2 != C.CL_SUCCESS {
-			C.clReleaseProgram(program)
-			return fmt.Errorf("clGetProgramBuildInfo Err: %v", err2)
-		}
+	runner.releasing.Lock()
+	leaks, err := runner.tracker.releaseAll(false)
+	runner.releasing.Unlock()
+	runner.tracker.reportLeaks(leaks)
+	if runner.cleanup != nil {
+		runtime.SetFinalizer(runner.cleanup, nil)
+		runner.cleanup = nil
+	}
+	runner.tracker = nil
+	if runner.trace != nil {
+		err = errors.Join(err, runner.trace.close())
+		runner.trace = nil
+	}
 
-		var log_buf = make([]byte, logSize, logSize)
-		err2 = C.clGetProgramBuildInfo(program, runner.Device.Device_id, C.CL_PROGRAM_BUILD_LOG, logSize, unsafe.Pointer(&log_buf[0]), nil)
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	runner.Programs = nil
+	runner.Kernels = nil
+	runner.Buffers = nil
+	runner.Queues = nil
+	runner.CommandQueue = nil
+	runner.Context = nil
+	runner.context = nil
+	return err
+}
 
-		if err2 != C.CL_SUCCESS {
-			C.clReleaseProgram(program)
-			return fmt.Errorf("clGetProgramBuildInfo Err: %v", err2)
-		}
+// CompileKernels compiles OpenCL kernels from the provided source code and adds the resulting program to the runner.
+// It is a shorthand for BuildProgram followed by AddProgram.
+func (runner *OpenCLRunner) CompileKernels(codeSourceList []string, kernelNameList []string, options string) error {
+	var call = runner.beforeHooks(context.Background(), Operation{Name: "CompileKernels", Kernels: kernelNameList})
+	var err = runner.compileKernels(codeSourceList, kernelNameList, false, options)
+	call.after(err)
+	return err
+}
 
-		fmt.Printf("clBuildProgram Err log: %s\n", string(log_buf))
+// CompileAllKernels is CompileKernels for every kernel defined by the source code,
+// so none of them may be defined by a program the runner already has.
+func (runner *OpenCLRunner) CompileAllKernels(codeSourceList []string, options string) error {
+	var call = runner.beforeHooks(context.Background(), Operation{Name: "CompileAllKernels"})
+	var err = runner.compileKernels(codeSourceList, nil, true, options)
+	call.after(err)
+	return err
+}
 
-		C.clReleaseProgram(program)
-		return fmt.Errorf("clBuildProgram Err: %v", err)
+func (runner *OpenCLRunner) compileKernels(codeSourceList []string, kernelNameList []string, all bool, options string) error {
+	program, err := runner.tracedBuildProgram(codeSourceList, kernelNameList, all, options)
+	if err != nil {
+		return err
 	}
-
-	// clCreateKernel
-	runner.Kernels = make(map[string]C.cl_kernel)
-	for _, kernelName := range kernelNameList {
-		var kernel_name = C.CString(kernelName)
-		defer C.free(unsafe.Pointer(kernel_name))
-
-		var kernel = C.clCreateKernel(program, kernel_name, &err)
-		if err != C.CL_SUCCESS {
-			C.clReleaseProgram(program)
-			return fmt.Errorf("clCreateKernel Err: %v", err)
-		}
-		runner.Kernels[kernelName] = kernel
+	if err = runner.AddProgram(program); err != nil {
+		program.Release()
+		return err
 	}
-
-	runner.Program = program
-
 	return nil
 }
 
@@ -162,89 +318,345 @@ func CreateBuffer[E any](runner *OpenCLRunner, flags C.cl_mem_flags, source []E)
 	if len(source) == 0 {
 		return nil, fmt.Errorf("clCreateBuffer Err: source is empty")
 	}
-	var err C.cl_int
-	size := C.size_t(int(unsafe.Sizeof(source[0])) * len(source))
+	size := int(unsafe.Sizeof(source[0])) * len(source)
 	host_ptr := unsafe.Pointer(&source[0])
-	cl_mem := C.clCreateBuffer(runner.Context, flags, size, host_ptr, &err)
-	if err != C.CL_SUCCESS {
-		return nil, fmt.Errorf("clCreateBuffer Err: %v", err)
-	}
-
-	buffer := &Buffer{cl_mem}
-	runner.Buffers = append(runner.Buffers, buffer)
-	return buffer, nil
+	return runner.createBuffer(flags, size, host_ptr)
 }
 
 // CreateEmptyBuffer creates an empty OpenCL buffer with the specified flags and size.
 func (runner *OpenCLRunner) CreateEmptyBuffer(flags C.cl_mem_flags, size int) (*Buffer, error) {
-	var err C.cl_int
-	cl_mem := C.clCreateBuffer(runner.Context, flags, C.size_t(size), nil, &err)
-	if err != C.CL_SUCCESS {
-		return nil, fmt.Errorf("clCreateBuffer Err: %v", err)
+	return runner.createBuffer(flags, size, nil)
+}
+
+func (runner *OpenCLRunner) createBuffer(flags C.cl_mem_flags, size int, host unsafe.Pointer) (*Buffer, error) {
+	var start = time.Now()
+	var call = runner.beforeHooks(context.Background(), Operation{Name: "CreateBuffer", Bytes: size})
+	mem, err := runner.context.NewBuffer(flags, size, host)
+	var buffer *Buffer
+	if err == nil {
+		buffer = runner.newBuffer(mem, size)
+	}
+	call.after(err)
+	if runner.timeline != nil {
+		runner.timeline.call("CreateBuffer", start, map[string]any{"bytes": size})
+	}
+	if runner.trace != nil {
+		var record = TraceRecord{Op: TraceCreateBuffer, Flags: uint64(flags), Size: size}
+		if buffer != nil {
+			buffer.id = runner.trace.id()
+			record.Buffer = buffer.id
+		}
+		if host != nil {
+			runner.trace.data(&record, hostBytes(host, size))
+		}
+		runner.trace.record(start, &record, err)
+	}
+	return buffer, err
+}
+
+// newBuffer wraps a memory object created by the runner and adds it to runner.Buffers.
+func (runner *OpenCLRunner) newBuffer(mem BackendBuffer, size int) *Buffer {
+	var buffer = &Buffer{buffer: mem, size: size}
+	// the release function must not reference the runner, or the runner could never be finalized
+	var tracker = runner.tracker
+	tracker.memory.Add(int64(size))
+	buffer.obj = tracker.track("buffer", "", "clReleaseMemObject", false, func() ErrorCode {
+		tracker.memory.Add(-int64(size))
+		return mem.Release()
+	})
+	if runner.tracker.finalizers {
+		runtime.SetFinalizer(buffer, (*Buffer).finalize)
 	}
-	buffer := &Buffer{cl_mem}
-	runner.Buffers = append(runner.Buffers, buffer)
-	return buffer, nil
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	// drop the buffers released with Buffer.Release
+	var buffers = runner.Buffers[:0]
+	for _, b := range runner.Buffers {
+		if !b.released.Load() {
+			buffers = append(buffers, b)
+		}
+	}
+	clear(runner.Buffers[len(buffers):])
+	runner.Buffers = append(buffers, buffer)
+	return buffer
 }
 
 // ReadBuffer reads data from an OpenCL buffer into the target slice.
-func ReadBuffer[E any](runner *OpenCLRunner, offset int, buffer *Buffer, target []E) error {
+func ReadBuffer[E any](runner Enqueuer, offset int, buffer *Buffer, target []E) error {
+	var call = transferHooks(context.Background(), "ReadBuffer", runner, target)
+	_, err := enqueueReadBuffer(runner, offset, buffer, target, true, nil, false)
+	call.after(err)
+	return err
+}
+
+// ReadBufferCtx reads data from an OpenCL buffer into the target slice like ReadBuffer, but returns ctx.Err()
+// if ctx is done before the read has completed.
+//
+// The read is not cancelled: it stays enqueued, the commands enqueued after it on the queue run after it,
+// and target may still be written until it has completed, which Finish on the queue waits for.
+// Until then target is kept pinned and must not be accessed.
+func ReadBufferCtx[E any](ctx context.Context, runner Enqueuer, offset int, buffer *Buffer, target []E) error {
+	var call = transferHooks(ctx, "ReadBuffer", runner, target)
+	event, err := enqueueReadBuffer(runner, offset, buffer, target, false, nil, true)
+	if err == nil {
+		err = event.waitRelease(ctx)
+	}
+	call.after(err)
+	return err
+}
+
+// EnqueueReadBuffer enqueues a read from an OpenCL buffer into the target slice after the events in waitList.
+// If blocking is false, target must not be accessed until the returned event has completed.
+func EnqueueReadBuffer[E any](runner Enqueuer, offset int, buffer *Buffer, target []E, blocking bool, waitList []*Event) (*Event, error) {
+	var call = transferHooks(context.Background(), "EnqueueReadBuffer", runner, target)
+	event, err := enqueueReadBuffer(runner, offset, buffer, target, blocking, waitList, true)
+	call.after(err)
+	return event, err
+}
+
+// transferHooks calls the Before hooks of a read or write of data.
+func transferHooks[E any](ctx context.Context, name string, runner Enqueuer, data []E) *hookCall {
+	var queue = runner.commandQueue()
+	var size = int(unsafe.Sizeof(*new(E))) * len(data)
+	return queue.runner.beforeHooks(ctx, Operation{Name: name, Queue: queue.Index, Bytes: size})
+}
+
+func enqueueReadBuffer[E any](runner Enqueuer, offset int, buffer *Buffer, target []E, blocking bool,
+	waitList []*Event, withEvent bool) (*Event, error) {
 	if len(target) == 0 {
-		return fmt.Errorf("clEnqueueReadBuffer Err: target is nil")
+		return nil, fmt.Errorf("clEnqueueReadBuffer Err: target is nil")
 	}
-	err := C.clEnqueueReadBuffer(runner.CommandQueue, buffer.buffer, C.CL_TRUE, C.size_t(offset),
-		C.size_t(int(unsafe.Sizeof(target[0]))*len(target)),
-		unsafe.Pointer(&target[0]), 0, nil, nil)
-	if err != C.CL_SUCCESS {
-		return fmt.Errorf("clEnqueueReadBuffer Err: %v", err)
+
+	var queue = runner.commandQueue()
+	var pinner *runtime.Pinner
+	if withEvent && !blocking {
+		pinner = &runtime.Pinner{}
+		pinner.Pin(&target[0])
 	}
-	return nil
+
+	var start = time.Now()
+	var size = int(unsafe.Sizeof(target[0])) * len(target)
+	event, err := queue.queue.EnqueueRead(buffer.buffer, blocking, offset, size,
+		unsafe.Pointer(&target[0]), eventWaitList(waitList), withEvent || queue.runner.profiling)
+	if err != nil {
+		if pinner != nil {
+			pinner.Unpin()
+		}
+		err = withBuffer(err, buffer)
+	}
+	var cmd = queue.transferCommand("ReadBuffer", start, offset, size)
+	if queue.runner.timeline != nil {
+		queue.runner.timeline.call(hostCall(cmd.name, withEvent), start, cmd.args)
+	}
+	var trace = queue.runner.trace
+	var index = -1
+	if trace != nil {
+		var record = TraceRecord{Op: TraceReadBuffer, Queue: queue.Index, Buffer: buffer.id, Offset: offset, Size: size, Blocking: blocking}
+		if blocking && err == nil {
+			trace.data(&record, hostBytes(unsafe.Pointer(&target[0]), size))
+		}
+		index = trace.record(start, &record, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e = queue.runner.enqueued(event, pinner, cmd, blocking, withEvent)
+	if e != nil && !blocking && index >= 0 && trace.content > TraceSizes {
+		// the data is recorded once the read has completed
+		e.onComplete = func() {
+			var record = TraceRecord{Op: TraceReadResult, Queue: queue.Index, Buffer: buffer.id, Ref: index}
+			trace.data(&record, hostBytes(unsafe.Pointer(&target[0]), size))
+			trace.record(time.Now(), &record, nil)
+		}
+	}
+	return e, nil
 }
 
 // WriteBuffer writes data from the source slice to an OpenCL buffer.
-func WriteBuffer[E any](runner *OpenCLRunner, offset int, buffer *Buffer, source []E, blocking bool) error {
+func WriteBuffer[E any](runner Enqueuer, offset int, buffer *Buffer, source []E, blocking bool) error {
+	var call = transferHooks(context.Background(), "WriteBuffer", runner, source)
+	_, err := enqueueWriteBuffer(runner, offset, buffer, source, blocking, nil, false)
+	call.after(err)
+	return err
+}
+
+// WriteBufferCtx writes data from the source slice to an OpenCL buffer and waits for the write to complete,
+// but returns ctx.Err() if ctx is done first.
+//
+// The write is not cancelled: it stays enqueued, the commands enqueued after it on the queue run after it,
+// and source may still be read until it has completed, which Finish on the queue waits for.
+// Until then source is kept pinned and must not be modified.
+func WriteBufferCtx[E any](ctx context.Context, runner Enqueuer, offset int, buffer *Buffer, source []E) error {
+	var call = transferHooks(ctx, "WriteBuffer", runner, source)
+	event, err := enqueueWriteBuffer(runner, offset, buffer, source, false, nil, true)
+	if err == nil {
+		err = event.waitRelease(ctx)
+	}
+	call.after(err)
+	return err
+}
+
+// EnqueueWriteBuffer enqueues a write from the source slice to an OpenCL buffer after the events in waitList.
+// If blocking is false, source must not be modified until the returned event has completed.
+func EnqueueWriteBuffer[E any](runner Enqueuer, offset int, buffer *Buffer, source []E, blocking bool, waitList []*Event) (*Event, error) {
+	var call = transferHooks(context.Background(), "EnqueueWriteBuffer", runner, source)
+	event, err := enqueueWriteBuffer(runner, offset, buffer, source, blocking, waitList, true)
+	call.after(err)
+	return event, err
+}
+
+func enqueueWriteBuffer[E any](runner Enqueuer, offset int, buffer *Buffer, source []E, blocking bool,
+	waitList []*Event, withEvent bool) (*Event, error) {
 	if len(source) == 0 {
-		return fmt.Errorf("clEnqueueWriteBuffer Err: source is empty")
+		return nil, fmt.Errorf("clEnqueueWriteBuffer Err: source is empty")
 	}
-	var _blocking C.cl_bool = C.CL_FALSE
-	if blocking {
-		_blocking = C.CL_TRUE
+
+	var queue = runner.commandQueue()
+	var pinner *runtime.Pinner
+	if withEvent && !blocking {
+		pinner = &runtime.Pinner{}
+		pinner.Pin(&source[0])
 	}
-	err := C.clEnqueueWriteBuffer(runner.CommandQueue, buffer.buffer, _blocking, C.size_t(offset),
-		C.size_t(int(unsafe.Sizeof(source[0]))*len(source)), unsafe.Pointer(&source[0]), 0, nil, nil)
-	if err != C.CL_SUCCESS {
-		return fmt.Errorf("clEnqueueWriteBuffer Err: %v", err)
+
+	var start = time.Now()
+	var size = int(unsafe.Sizeof(source[0])) * len(source)
+	event, err := queue.queue.EnqueueWrite(buffer.buffer, blocking, offset, size,
+		unsafe.Pointer(&source[0]), eventWaitList(waitList), withEvent || queue.runner.profiling)
+	if err != nil {
+		if pinner != nil {
+			pinner.Unpin()
+		}
+		err = withBuffer(err, buffer)
 	}
-	return nil
+	var cmd = queue.transferCommand("WriteBuffer", start, offset, size)
+	if queue.runner.timeline != nil {
+		queue.runner.timeline.call(hostCall(cmd.name, withEvent), start, cmd.args)
+	}
+	if trace := queue.runner.trace; trace != nil {
+		var record = TraceRecord{Op: TraceWriteBuffer, Queue: queue.Index, Buffer: buffer.id, Offset: offset, Size: size, Blocking: blocking}
+		trace.data(&record, hostBytes(unsafe.Pointer(&source[0]), size))
+		trace.record(start, &record, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return queue.runner.enqueued(event, pinner, cmd, blocking, withEvent), nil
 }
 
-// ReleaseBuffer releases the specified OpenCL buffer.
-func (runner *OpenCLRunner) ReleaseBuffer(buffer *Buffer) error {
-	err := C.clReleaseMemObject(buffer.buffer)
-	if err != C.CL_SUCCESS {
-		return fmt.Errorf("clReleaseMemObject Err: %v", err)
+const (
+	MAP_READ  C.cl_map_flags = C.CL_MAP_READ
+	MAP_WRITE                = C.CL_MAP_WRITE
+)
+
+// MapBuffer maps count elements of an OpenCL buffer, starting at offset bytes, into host memory.
+// It blocks until the mapping is available. The slice must not be used after UnmapBuffer.
+// For buffers created with ALLOC_HOST_PTR, the mapped memory is usually pinned, which makes
+// it a fast source or target for ReadBuffer and WriteBuffer on other buffers.
+func MapBuffer[E any](runner Enqueuer, buffer *Buffer, flags C.cl_map_flags, offset int, count int) ([]E, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("clEnqueueMapBuffer Err: count is %d", count)
 	}
-	return nil
+	var queue = runner.commandQueue()
+	var start = time.Now()
+	var size = int(unsafe.Sizeof(*new(E))) * count
+	ptr, err := queue.queue.MapBuffer(buffer.buffer, flags, offset, size)
+	if err != nil {
+		err = withBuffer(err, buffer)
+	}
+	if queue.runner.timeline != nil {
+		queue.runner.timeline.call("MapBuffer", start, map[string]any{"bytes": size, "offset": offset})
+	}
+	if trace := queue.runner.trace; trace != nil {
+		var record = TraceRecord{Op: TraceMapBuffer, Queue: queue.Index, Buffer: buffer.id, Flags: uint64(flags), Offset: offset, Size: size}
+		if err == nil {
+			trace.data(&record, hostBytes(ptr, size))
+		}
+		if index := trace.record(start, &record, err); index >= 0 && err == nil {
+			trace.mu.Lock()
+			trace.mapped[ptr] = index
+			trace.mu.Unlock()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unsafe.Slice((*E)(ptr), count), nil
+}
+
+// UnmapBuffer unmaps a slice returned by MapBuffer and blocks until the unmapping has completed.
+func UnmapBuffer[E any](runner Enqueuer, buffer *Buffer, mapped []E) error {
+	if len(mapped) == 0 {
+		return fmt.Errorf("clEnqueueUnmapMemObject Err: mapped is empty")
+	}
+	var queue = runner.commandQueue()
+	var start = time.Now()
+	var ptr = unsafe.Pointer(&mapped[0])
+	var trace = queue.runner.trace
+	var record TraceRecord
+	if trace != nil {
+		// the data is recorded before unmapping, while it can still be read
+		record = TraceRecord{Op: TraceUnmapBuffer, Queue: queue.Index, Buffer: buffer.id, Ref: -1}
+		trace.mu.Lock()
+		if index, ok := trace.mapped[ptr]; ok {
+			record.Ref = index
+			delete(trace.mapped, ptr)
+		}
+		trace.mu.Unlock()
+		trace.data(&record, hostBytes(ptr, int(unsafe.Sizeof(mapped[0]))*len(mapped)))
+	}
+	var err = queue.queue.UnmapBuffer(buffer.buffer, ptr)
+	if err != nil {
+		err = withBuffer(err, buffer)
+	}
+	if queue.runner.timeline != nil {
+		queue.runner.timeline.call("UnmapBuffer", start, map[string]any{"bytes": int(unsafe.Sizeof(mapped[0])) * len(mapped)})
+	}
+	if trace != nil {
+		trace.record(start, &record, err)
+	}
+	return err
 }
 
-// map_size_t converts a slice of uint64 to a slice of C.size_t.
-func map_size_t[E uint64](slice []E) []C.size_t {
-	size_t_slice := make([]C.size_t, len(slice), len(slice))
-	for i, v := range slice {
-		size_t_slice[i] = C.size_t(v)
+// ReleaseBuffer releases the specified OpenCL buffer and removes it from runner.Buffers.
+// Releasing a buffer more than once has no effect.
+func (runner *OpenCLRunner) ReleaseBuffer(buffer *Buffer) error {
+	runner.mu.Lock()
+	for i, b := range runner.Buffers {
+		if b == buffer {
+			runner.Buffers = append(runner.Buffers[:i], runner.Buffers[i+1:]...)
+			break
+		}
 	}
-	return size_t_slice
+	runner.mu.Unlock()
+	return buffer.Release()
 }
 
 // KernelParam represents a parameter for an OpenCL kernel.
 type KernelParam struct {
 	Size    uintptr
-	Pointer unsafe.Pointer
+	Pointer unsafe.Pointer // nil for buffers and local memory
+
+	buffer *Buffer
 }
 
 // BufferParam creates a KernelParam for an OpenCL buffer.
 func BufferParam(v *Buffer) KernelParam {
-	return KernelParam{Size: unsafe.Sizeof(v.buffer), Pointer: unsafe.Pointer(&v.buffer)}
+	return KernelParam{Size: unsafe.Sizeof(uintptr(0)), buffer: v}
+}
+
+// Buffer returns the backend buffer of a BufferParam, or nil for other parameters.
+// It is meant for Backend implementations.
+func (param KernelParam) Buffer() BackendBuffer {
+	if param.buffer == nil {
+		return nil
+	}
+	return param.buffer.buffer
+}
+
+// LocalParam creates a KernelParam for a __local argument of the given size in bytes.
+func LocalParam(size int) KernelParam {
+	return KernelParam{Size: uintptr(size)}
 }
 
 // Param creates a KernelParam for a value.
@@ -252,66 +664,63 @@ func Param[E any](v *E) KernelParam {
 	return KernelParam{Size: unsafe.Sizeof(*v), Pointer: unsafe.Pointer(v)}
 }
 
-// SetKernelArgs sets the arguments for a specific OpenCL kernel.
+// SetKernelArgs validates and sets the arguments for a specific OpenCL kernel.
+// The arguments are shared by all goroutines; concurrent RunKernel calls should pass their own arguments instead.
 func (runner *OpenCLRunner) SetKernelArgs(kernelName string, args []KernelParam) error {
-	var kernel = runner.Kernels[kernelName]
-	var err C.cl_int
-	for i, arg := range args {
-		err = C.clSetKernelArg(kernel, C.cl_uint(i), C.size_t(arg.Size), arg.Pointer)
-		if err != C.CL_SUCCESS {
-			return fmt.Errorf("clSetKernelArg Err: %v", err)
-		}
+	kernel, err := runner.kernel("SetKernelArgs", kernelName)
+	if err != nil {
+		return err
 	}
-	return nil
+	return kernel.SetArgs(args)
 }
 
-// RunKernel runs an OpenCL kernel with the specified work dimensions, work sizes, and arguments.
+// RunKernel runs an OpenCL kernel on the first command queue with the specified work dimensions, work sizes, and arguments.
+// If wait is true, RunKernel blocks until the kernel has completed.
 func (runner *OpenCLRunner) RunKernel(kernelName string, work_dim int,
 	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam, wait bool) error {
-	var kernel = runner.Kernels[kernelName]
-	var err C.cl_int
-	for i, arg := range args {
-		err = C.clSetKernelArg(kernel, C.cl_uint(i), C.size_t(arg.Size), arg.Pointer)
-		if err != C.CL_SUCCESS {
-			return fmt.Errorf("clSetKernelArg Err: %v", err)
-		}
-	}
-
-	var global_work_offset_ptr, global_work_size_ptr, local_work_size_ptr *C.size_t = nil, nil, nil
-
-	if len(global_work_offset) != 0 {
-		_global_work_offset := map_size_t(global_work_offset)
-		global_work_offset_ptr = &_global_work_offset[0]
-	}
+	return runner.Queues[0].RunKernel(kernelName, work_dim, global_work_offset, global_work_size, local_work_size, args, wait)
+}
 
-	if len(global_work_size) != 0 {
-		_global_work_size := map_size_t(global_work_size)
-		global_work_size_ptr = &_global_work_size[0]
-	}
+// RunKernelCtx runs an OpenCL kernel on the first command queue like Queue.RunKernelCtx,
+// returning ctx.Err() if ctx is done before the kernel has completed.
+func (runner *OpenCLRunner) RunKernelCtx(ctx context.Context, kernelName string, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam) error {
+	return runner.Queues[0].RunKernelCtx(ctx, kernelName, work_dim, global_work_offset, global_work_size, local_work_size, args)
+}
 
-	if len(local_work_size) != 0 {
-		_local_work_size := map_size_t(local_work_size)
-		local_work_size_ptr = &_local_work_size[0]
-	}
+// EnqueueKernel enqueues an OpenCL kernel on the first command queue to run after the events in waitList and returns its event.
+func (runner *OpenCLRunner) EnqueueKernel(kernelName string, work_dim int,
+	global_work_offset []uint64, global_work_size []uint64, local_work_size []uint64, args []KernelParam,
+	waitList []*Event) (*Event, error) {
+	return runner.Queues[0].EnqueueKernel(kernelName, work_dim, global_work_offset, global_work_size, local_work_size, args, waitList)
+}
 
-	var evt *C.cl_event = nil
-	if wait {
-		var evt_obj C.cl_event
-		evt = &evt_obj
-		defer C.clReleaseEvent(evt_obj)
+// enqueued wraps the event of an enqueued command.
+// Events the caller did not ask for only exist for profiling: they are recorded right away
+// if the command has completed, or kept until ProfilingReport or Free otherwise. The completed ones
+// are collected whenever the number of kept events doubles, so that the list stays bounded.
+func (runner *OpenCLRunner) enqueued(event BackendEvent, pinner *runtime.Pinner, cmd command, blocking bool, withEvent bool) *Event {
+	if event == nil {
+		return nil
 	}
-	err = C.clEnqueueNDRangeKernel(runner.CommandQueue, kernel, C.cl_uint(work_dim),
-		global_work_offset_ptr, global_work_size_ptr, local_work_size_ptr, 0, nil, evt)
-	if err != C.CL_SUCCESS {
-		return fmt.Errorf("clEnqueueNDRangeKernel Err: %v", err)
+	var e = &Event{event: event, pinner: pinner, runner: runner, command: cmd}
+	e.obj = runner.tracker.track("event", cmd.name, "clReleaseEvent", !withEvent, event.Release)
+	if withEvent {
+		if runner.tracker.finalizers {
+			runtime.SetFinalizer(e, (*Event).finalize)
+		}
+		return e
 	}
-
-	if wait {
-		err = C.clWaitForEvents(1, evt)
-		if err != C.CL_SUCCESS {
-			return fmt.Errorf("clWaitForEvents Err: %v", err)
+	if blocking {
+		e.Release()
+	} else {
+		runner.mu.Lock()
+		runner.pendingEvents = append(runner.pendingEvents, e)
+		var collect = len(runner.pendingEvents) >= runner.pendingLimit
+		runner.mu.Unlock()
+		if collect {
+			runner.collectPendingEvents()
 		}
 	}
-
 	return nil
 }
@@ -2,8 +2,12 @@
 package opencl
 
 import (
+	"errors"
+	"fmt"
 	"slices"
+	"sync"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -82,3 +86,362 @@ func TestRunner(t *testing.T) {
 	}
 
 }
+
+// TestRunnerPrograms tests loading several programs into one runner.
+func TestRunnerPrograms(t *testing.T) {
+	info, _ := Info()
+	if len(info.Platforms) < 1 || len(info.Platforms[0].Devices) < 1 {
+		t.Skipf("No OpenCL Devices")
+	}
+
+	runner, err := info.Platforms[0].Devices[0].InitRunner()
+	if err != nil {
+		t.Fatal("InitRunner err:", err)
+	}
+	defer runner.Free()
+
+	err = runner.CompileKernels([]string{`__kernel void square(__global int* v) { int i = get_global_id(0); v[i] *= v[i]; }`},
+		[]string{"square"}, "")
+	if err != nil {
+		t.Fatal("CompileKernels err:", err)
+	}
+
+	program, err := runner.BuildProgram([]string{`__kernel void square(__global int* v) { }
+		__kernel void negate(__global int* v) { int i = get_global_id(0); v[i] = -v[i]; }`},
+		[]string{"square", "negate"}, "")
+	if err != nil {
+		t.Fatal("BuildProgram err:", err)
+	}
+	if err = runner.AddProgram(program); err == nil {
+		t.Fatal("AddProgram accepted a duplicate kernel name")
+	}
+	if _, ok := runner.Kernels["negate"]; ok {
+		t.Fatal("AddProgram added kernels of a rejected program")
+	}
+	program.Release()
+
+	if err = runner.RunKernel("missing", 1, nil, []uint64{1}, nil, nil, true); !errors.Is(err, ErrInvalidKernelName) {
+		t.Fatal("RunKernel with an unknown kernel returned:", err)
+	}
+
+	err = runner.CompileKernels([]string{`__kernel void negate(__global int* v) { int i = get_global_id(0); v[i] = -v[i]; }`},
+		[]string{"negate"}, "")
+	if err != nil {
+		t.Fatal("CompileKernels err:", err)
+	}
+
+	input := []int32{1, 2, 3}
+	buf, err := CreateBuffer(runner, READ_WRITE|COPY_HOST_PTR, input)
+	if err != nil {
+		t.Fatal("CreateBuffer err:", err)
+	}
+	for _, kernelName := range []string{"square", "negate"} {
+		err = runner.RunKernel(kernelName, 1, nil, []uint64{uint64(len(input))}, nil, []KernelParam{BufferParam(buf)}, true)
+		if err != nil {
+			t.Fatal("RunKernel err:", err)
+		}
+	}
+	result := make([]int32, len(input))
+	if err = ReadBuffer(runner, 0, buf, result); err != nil {
+		t.Fatal("ReadBuffer err:", err)
+	}
+	if !slices.Equal(result, []int32{-1, -4, -9}) {
+		t.Fatal("result error:", result)
+	}
+
+	square := runner.Programs[0]
+	if err = runner.RemoveProgram(square); err != nil {
+		t.Fatal("RemoveProgram err:", err)
+	}
+	square.Release()
+	if _, ok := runner.Kernels["square"]; ok {
+		t.Fatal("RemoveProgram kept the kernels")
+	}
+}
+
+// TestRunnerEvents tests an upload/compute/download pipeline chained with events.
+func TestRunnerEvents(t *testing.T) {
+	info, _ := Info()
+	if len(info.Platforms) < 1 || len(info.Platforms[0].Devices) < 1 {
+		t.Skipf("No OpenCL Devices")
+	}
+
+	runner, err := info.Platforms[0].Devices[0].InitRunner()
+	if err != nil {
+		t.Fatal("InitRunner err:", err)
+	}
+	defer runner.Free()
+
+	err = runner.CompileKernels([]string{`__kernel void square(__global int* v) { int i = get_global_id(0); v[i] *= v[i]; }`},
+		[]string{"square"}, "")
+	if err != nil {
+		t.Fatal("CompileKernels err:", err)
+	}
+
+	input := []int32{1, 2, 3, 4}
+	buf, err := runner.CreateEmptyBuffer(READ_WRITE, len(input)*4)
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+
+	upload, err := EnqueueWriteBuffer(runner, 0, buf, input, false, nil)
+	if err != nil {
+		t.Fatal("EnqueueWriteBuffer err:", err)
+	}
+	defer upload.Release()
+	compute, err := runner.EnqueueKernel("square", 1, nil, []uint64{uint64(len(input))}, nil,
+		[]KernelParam{BufferParam(buf)}, []*Event{upload})
+	if err != nil {
+		t.Fatal("EnqueueKernel err:", err)
+	}
+	defer compute.Release()
+	result := make([]int32, len(input))
+	download, err := EnqueueReadBuffer(runner, 0, buf, result, false, []*Event{compute})
+	if err != nil {
+		t.Fatal("EnqueueReadBuffer err:", err)
+	}
+	defer download.Release()
+
+	if err = WaitAll(upload, compute, download); err != nil {
+		t.Fatal("WaitAll err:", err)
+	}
+	if status, err := download.Status(); err != nil || status != Complete {
+		t.Fatal("Status:", status, err)
+	}
+	if !slices.Equal(result, []int32{1, 4, 9, 16}) {
+		t.Fatal("result error:", result)
+	}
+}
+
+// TestRunnerProfiling tests the per-kernel profiling report.
+func TestRunnerProfiling(t *testing.T) {
+	info, _ := Info()
+	if len(info.Platforms) < 1 || len(info.Platforms[0].Devices) < 1 {
+		t.Skipf("No OpenCL Devices")
+	}
+
+	runner, err := info.Platforms[0].Devices[0].InitRunner(WithProfiling())
+	if err != nil {
+		t.Fatal("InitRunner err:", err)
+	}
+	defer runner.Free()
+
+	err = runner.CompileKernels([]string{`__kernel void square(__global int* v) { int i = get_global_id(0); v[i] *= v[i]; }`},
+		[]string{"square"}, "")
+	if err != nil {
+		t.Fatal("CompileKernels err:", err)
+	}
+
+	input := []int32{1, 2, 3, 4}
+	buf, err := CreateBuffer(runner, READ_WRITE|COPY_HOST_PTR, input)
+	if err != nil {
+		t.Fatal("CreateBuffer err:", err)
+	}
+	for i := 0; i < 3; i++ {
+		err = runner.RunKernel("square", 1, nil, []uint64{uint64(len(input))}, nil, []KernelParam{BufferParam(buf)}, i == 2)
+		if err != nil {
+			t.Fatal("RunKernel err:", err)
+		}
+	}
+	if err = ReadBuffer(runner, 0, buf, input); err != nil {
+		t.Fatal("ReadBuffer err:", err)
+	}
+
+	report := runner.ProfilingReport()
+	if len(report) != 2 || report[0].Name != "ReadBuffer" || report[1].Name != "square" || report[1].Count != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report[1].Min > report[1].Mean || report[1].Mean > report[1].Max {
+		t.Fatalf("inconsistent stats: %+v", report[1])
+	}
+}
+
+// TestPlatformRunner tests a runner sharing one context across all devices of a platform.
+func TestPlatformRunner(t *testing.T) {
+	info, _ := Info()
+	if len(info.Platforms) < 1 || len(info.Platforms[0].Devices) < 1 {
+		t.Skipf("No OpenCL Devices")
+	}
+
+	platform := info.Platforms[0]
+	runner, err := platform.InitRunner(nil)
+	if err != nil {
+		t.Fatal("InitRunner err:", err)
+	}
+	defer runner.Free()
+	if len(runner.Queues) != len(platform.Devices) {
+		t.Fatal("unexpected number of queues:", len(runner.Queues))
+	}
+
+	err = runner.CompileKernels([]string{`__kernel void inc(__global int* v) { v[get_global_id(0)] += 1; }`},
+		[]string{"inc"}, "")
+	if err != nil {
+		t.Fatal("CompileKernels err:", err)
+	}
+
+	data := []int32{0, 0, 0}
+	buf, err := CreateBuffer(runner, READ_WRITE|COPY_HOST_PTR, data)
+	if err != nil {
+		t.Fatal("CreateBuffer err:", err)
+	}
+	for _, device := range platform.Devices {
+		queue, err := runner.DeviceQueue(device)
+		if err != nil {
+			t.Fatal("DeviceQueue err:", err)
+		}
+		err = queue.RunKernel("inc", 1, nil, []uint64{uint64(len(data))}, nil, []KernelParam{BufferParam(buf)}, true)
+		if err != nil {
+			t.Fatal("RunKernel err:", err)
+		}
+	}
+	if err = ReadBuffer(runner, 0, buf, data); err != nil {
+		t.Fatal("ReadBuffer err:", err)
+	}
+	if n := int32(len(platform.Devices)); !slices.Equal(data, []int32{n, n, n}) {
+		t.Fatal("result error:", data)
+	}
+}
+
+// TestMapBuffer tests mapping a host buffer and using it as a transfer source.
+func TestMapBuffer(t *testing.T) {
+	info, _ := Info()
+	if len(info.Platforms) < 1 || len(info.Platforms[0].Devices) < 1 {
+		t.Skipf("No OpenCL Devices")
+	}
+
+	runner, err := info.Platforms[0].Devices[0].InitRunner()
+	if err != nil {
+		t.Fatal("InitRunner err:", err)
+	}
+	defer runner.Free()
+
+	hostBuffer, err := runner.CreateEmptyBuffer(READ_WRITE|ALLOC_HOST_PTR, 4*4)
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	mapped, err := MapBuffer[int32](runner, hostBuffer, MAP_READ|MAP_WRITE, 0, 4)
+	if err != nil {
+		t.Fatal("MapBuffer err:", err)
+	}
+	copy(mapped, []int32{1, 2, 3, 4})
+
+	buffer, err := runner.CreateEmptyBuffer(READ_WRITE, 4*4)
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	if err = WriteBuffer(runner, 0, buffer, mapped, true); err != nil {
+		t.Fatal("WriteBuffer err:", err)
+	}
+	if err = UnmapBuffer(runner, hostBuffer, mapped); err != nil {
+		t.Fatal("UnmapBuffer err:", err)
+	}
+
+	result := make([]int32, 4)
+	if err = ReadBuffer(runner, 0, buffer, result); err != nil {
+		t.Fatal("ReadBuffer err:", err)
+	}
+	if !slices.Equal(result, []int32{1, 2, 3, 4}) {
+		t.Fatal("result error:", result)
+	}
+}
+
+// TestRunnerConcurrent tests running one kernel from several goroutines, each with its own arguments.
+func TestRunnerConcurrent(t *testing.T) {
+	info, _ := Info()
+	if len(info.Platforms) < 1 || len(info.Platforms[0].Devices) < 1 {
+		t.Skipf("No OpenCL Devices")
+	}
+
+	runner, err := info.Platforms[0].Devices[0].InitRunner(WithQueues(2))
+	if err != nil {
+		t.Fatal("InitRunner err:", err)
+	}
+	defer runner.Free()
+
+	err = runner.CompileKernels([]string{`__kernel void add(__global int* v, int n) { v[get_global_id(0)] += n; }`},
+		[]string{"add"}, "")
+	if err != nil {
+		t.Fatal("CompileKernels err:", err)
+	}
+
+	var wg sync.WaitGroup
+	var errs = make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(n int32) {
+			defer wg.Done()
+			var queue = runner.Queues[int(n)%len(runner.Queues)]
+			input := []int32{1, 2, 3, 4}
+			buf, err := CreateBuffer(runner, READ_WRITE|COPY_HOST_PTR, input)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer runner.ReleaseBuffer(buf)
+			for i := 0; i < 10; i++ {
+				err = queue.RunKernel("add", 1, nil, []uint64{uint64(len(input))}, nil,
+					[]KernelParam{BufferParam(buf), Param(&n)}, true)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+			result := make([]int32, len(input))
+			if err = ReadBuffer(queue, 0, buf, result); err != nil {
+				errs <- err
+				return
+			}
+			for i, v := range input {
+				if result[i] != v+10*n {
+					errs <- fmt.Errorf("goroutine %d: result %v", n, result)
+					return
+				}
+			}
+		}(int32(g))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestRunnerConcurrentPrograms tests adding, removing and looking up programs from several goroutines.
+func TestRunnerConcurrentPrograms(t *testing.T) {
+	var fake fakeObjects
+	var runner = &OpenCLRunner{tracker: newTracker(&runnerConfig{})}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		var name = fmt.Sprintf("kernel%d", g)
+		var program = &Program{Kernels: map[string]*Kernel{name: {Name: name}}}
+		program.obj = fake.track(runner.tracker, "program", name, false)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if err := runner.AddProgram(program); err != nil {
+					t.Error("AddProgram err:", err)
+					return
+				}
+				if kernel, err := runner.Kernel(name); err != nil || kernel.Name != name {
+					t.Error("Kernel:", kernel, err)
+				}
+				runner.recordProfile(name, time.Microsecond)
+				runner.ProfilingReport()
+				if err := runner.RemoveProgram(program); err != nil {
+					t.Error("RemoveProgram err:", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(runner.Programs) != 0 || len(runner.Kernels) != 0 {
+		t.Errorf("%d programs and %d kernels left", len(runner.Programs), len(runner.Kernels))
+	}
+	for _, stats := range runner.ProfilingReport() {
+		if stats.Count != 100 {
+			t.Errorf("%s: %d profiles recorded, want 100", stats.Name, stats.Count)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DeviceEnv is the environment variable that overrides device selection.
+// Its value is "<platform index>:<device index>", e.g. "0:1" for the second device of the first platform.
+const DeviceEnv = "GO_OPENCL_DEVICE"
+
+// DeviceFilter describes the requirements and ranking used by SelectDevice.
+// Zero values do not filter.
+type DeviceFilter struct {
+	Types           DeviceType // any of the types, e.g. DeviceTypeGPU|DeviceTypeAccelerator
+	Vendor          string     // case-insensitive substring of the vendor name
+	Name            string     // case-insensitive substring of the device name
+	MinVersion      Version    // minimum OpenCL version of the device
+	MinGlobalMemory uint64     // minimum global memory size in bytes
+	Extensions      []string   // extensions the device must support
+
+	// Score ranks the matching devices, highest first. ComputeScore is used if nil.
+	Score func(device *OpenCLDevice) float64
+}
+
+// ComputeScore ranks devices by compute units × max clock frequency.
+func ComputeScore(device *OpenCLDevice) float64 {
+	return float64(device.Max_compute_units) * float64(device.Max_clock_frequency)
+}
+
+// MemoryScore ranks devices by global memory size.
+func MemoryScore(device *OpenCLDevice) float64 {
+	return float64(device.Global_mem_size)
+}
+
+// Match reports whether the device meets the requirements of the filter.
+func (filter *DeviceFilter) Match(device *OpenCLDevice) bool {
+	if filter.Types != 0 && device.Device_type&filter.Types == 0 {
+		return false
+	}
+	if filter.Vendor != "" && !strings.Contains(strings.ToLower(device.Vendor), strings.ToLower(filter.Vendor)) {
+		return false
+	}
+	if filter.Name != "" && !strings.Contains(strings.ToLower(device.Name), strings.ToLower(filter.Name)) {
+		return false
+	}
+	if filter.MinVersion != (Version{}) {
+		if !device.AtLeast(filter.MinVersion.Major, filter.MinVersion.Minor) {
+			return false
+		}
+	}
+	if device.Global_mem_size < filter.MinGlobalMemory {
+		return false
+	}
+	if len(filter.Extensions) > 0 {
+		for _, extension := range filter.Extensions {
+			if !device.Supports(extension) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SelectDevices returns the devices matching the filter, best first.
+func (info *OpenCLInfo) SelectDevices(filter DeviceFilter) []*OpenCLDevice {
+	var score = filter.Score
+	if score == nil {
+		score = ComputeScore
+	}
+
+	var devices []*OpenCLDevice
+	for _, platform := range info.Platforms {
+		for _, device := range platform.Devices {
+			if filter.Match(device) {
+				devices = append(devices, device)
+			}
+		}
+	}
+	sort.SliceStable(devices, func(i, j int) bool { return score(devices[i]) > score(devices[j]) })
+	return devices
+}
+
+// SelectDevice returns the best device matching the filter.
+// If the GO_OPENCL_DEVICE environment variable is set, the device it names is returned instead.
+func (info *OpenCLInfo) SelectDevice(filter DeviceFilter) (*OpenCLDevice, error) {
+	if env := os.Getenv(DeviceEnv); env != "" {
+		return info.deviceByIndex(env)
+	}
+	var devices = info.SelectDevices(filter)
+	if len(devices) == 0 {
+		return nil, clError("SelectDevice", C.CL_DEVICE_NOT_FOUND)
+	}
+	return devices[0], nil
+}
+
+// SelectDevice queries the OpenCL platforms with Info and returns the best device matching the filter.
+// If the GO_OPENCL_DEVICE environment variable is set, the device it names is returned instead.
+func SelectDevice(filter DeviceFilter) (*OpenCLDevice, error) {
+	info, err := Info()
+	if err != nil {
+		return nil, err
+	}
+	return info.SelectDevice(filter)
+}
+
+// deviceByIndex returns the device named by "<platform index>:<device index>".
+func (info *OpenCLInfo) deviceByIndex(s string) (*OpenCLDevice, error) {
+	var platformStr, deviceStr, found = strings.Cut(s, ":")
+	platformIndex, err1 := strconv.Atoi(platformStr)
+	deviceIndex, err2 := strconv.Atoi(deviceStr)
+	if !found || err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("%s: invalid value %q, expected <platform>:<device>", DeviceEnv, s)
+	}
+	if platformIndex < 0 || platformIndex >= len(info.Platforms) {
+		return nil, fmt.Errorf("%s: platform %d not found", DeviceEnv, platformIndex)
+	}
+	var platform = info.Platforms[platformIndex]
+	if deviceIndex < 0 || deviceIndex >= len(platform.Devices) {
+		return nil, fmt.Errorf("%s: device %d not found on platform %q", DeviceEnv, deviceIndex, platform.Name)
+	}
+	return platform.Devices[deviceIndex], nil
+}
@@ -0,0 +1,59 @@
+package opencl
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	for s, expected := range map[string]Version{
+		"OpenCL 1.2 CUDA 12.2.138":  {Major: 1, Minor: 2, Suffix: "CUDA 12.2.138"},
+		"OpenCL 3.0 ":               {Major: 3, Minor: 0},
+		"OpenCL C 2.0 Intel(R) SDK": {Major: 2, Minor: 0, Suffix: "Intel(R) SDK"},
+	} {
+		if version, err := ParseVersion(s); err != nil || version != expected {
+			t.Errorf("ParseVersion(%q) = %+v, %v", s, version, err)
+		}
+	}
+	if _, err := ParseVersion("CUDA 12"); err == nil {
+		t.Error("ParseVersion accepted an invalid version")
+	}
+	if v := (Version{Major: 2, Minor: 1}); !v.AtLeast(1, 2) || !v.AtLeast(2, 1) || v.AtLeast(2, 2) {
+		t.Error("AtLeast failed")
+	}
+}
+
+func TestSelectDevices(t *testing.T) {
+	igpu := &OpenCLDevice{Name: "Intel(R) UHD Graphics", Vendor: "Intel(R) Corporation", Version: "OpenCL 3.0 NEO", Parsed_version: Version{Major: 3},
+		Device_type: DeviceTypeGPU, Max_compute_units: 24, Max_clock_frequency: 1150, Global_mem_size: 8 << 30}
+	dgpu := &OpenCLDevice{Name: "NVIDIA GeForce RTX 3060", Vendor: "NVIDIA Corporation", Version: "OpenCL 3.0 CUDA", Parsed_version: Version{Major: 3},
+		Device_type: DeviceTypeGPU, Max_compute_units: 28, Max_clock_frequency: 1777, Global_mem_size: 6 << 30}
+	cpu := &OpenCLDevice{Name: "pthread-Intel(R) Core(TM) i7", Vendor: "GenuineIntel", Version: "OpenCL 1.2 pocl", Parsed_version: Version{Major: 1, Minor: 2},
+		Device_type: DeviceTypeCPU, Max_compute_units: 16, Max_clock_frequency: 4700, Global_mem_size: 32 << 30}
+	info := &OpenCLInfo{Platforms: []*OpenCLPlatform{
+		{Name: "Intel", Devices: []*OpenCLDevice{igpu}},
+		{Name: "NVIDIA", Devices: []*OpenCLDevice{dgpu}},
+		{Name: "pocl", Devices: []*OpenCLDevice{cpu}},
+	}}
+
+	if devices := info.SelectDevices(DeviceFilter{Types: DeviceTypeGPU}); len(devices) != 2 || devices[0] != dgpu {
+		t.Error("GPU selection failed:", devices)
+	}
+	if devices := info.SelectDevices(DeviceFilter{Score: MemoryScore}); devices[0] != cpu || devices[2] != dgpu {
+		t.Error("memory ranking failed:", devices)
+	}
+	if devices := info.SelectDevices(DeviceFilter{Vendor: "intel", MinVersion: Version{Major: 2}}); len(devices) != 1 || devices[0] != igpu {
+		t.Error("vendor and version selection failed:", devices)
+	}
+	if devices := info.SelectDevices(DeviceFilter{MinGlobalMemory: 64 << 30}); len(devices) != 0 {
+		t.Error("memory selection failed:", devices)
+	}
+
+	t.Setenv(DeviceEnv, "2:0")
+	if device, err := info.SelectDevice(DeviceFilter{Types: DeviceTypeGPU}); err != nil || device != cpu {
+		t.Error("environment override failed:", device, err)
+	}
+	t.Setenv(DeviceEnv, "1:1")
+	if _, err := info.SelectDevice(DeviceFilter{}); err == nil {
+		t.Error("environment override accepted a missing device")
+	}
+}
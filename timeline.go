@@ -0,0 +1,223 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WithTimeline records the host calls and the device commands of the runner for WriteTimeline.
+// It implies WithProfiling, which provides the device timestamps of the commands.
+func WithTimeline() RunnerOption {
+	return func(config *runnerConfig) {
+		config.queueProperties |= C.CL_QUEUE_PROFILING_ENABLE
+		config.timeline = true
+	}
+}
+
+// timeline collects the spans of a runner created WithTimeline.
+type timeline struct {
+	mu       sync.Mutex
+	start    time.Time
+	calls    []timelineCall
+	commands []timelineCommand
+}
+
+// timelineCall is a call of the runner API on the host.
+type timelineCall struct {
+	name  string
+	start time.Duration // since timeline.start
+	end   time.Duration
+	args  map[string]any
+}
+
+// timelineCommand is a command that completed on a device.
+type timelineCommand struct {
+	name     string
+	queue    *Queue
+	enqueued time.Duration // host time of the enqueue, since timeline.start
+	profile  Profile
+	args     map[string]any
+}
+
+// call adds a host call that started at start and ends now.
+func (t *timeline) call(name string, start time.Time, args map[string]any) {
+	var call = timelineCall{name: name, start: start.Sub(t.start), end: time.Since(t.start), args: args}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = append(t.calls, call)
+}
+
+// command adds a completed command.
+func (t *timeline) command(e *Event, profile Profile) {
+	var command = timelineCommand{name: e.name, queue: e.queue, enqueued: e.start.Sub(t.start), profile: profile, args: e.args}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.commands = append(t.commands, command)
+}
+
+// transferCommand describes a read or write of size bytes at offset.
+func (queue *Queue) transferCommand(name string, start time.Time, offset, size int) command {
+	var cmd = command{name: name, queue: queue, start: start}
+	if queue.runner.timeline != nil {
+		cmd.args = map[string]any{"bytes": size, "offset": offset}
+	}
+	return cmd
+}
+
+// kernelCommand describes a kernel enqueue.
+func (queue *Queue) kernelCommand(kernelName string, start time.Time, globalSize, localSize []uint64) command {
+	var cmd = command{name: kernelName, queue: queue, start: start}
+	if queue.runner.timeline != nil {
+		cmd.args = map[string]any{"global_size": globalSize}
+		if localSize != nil {
+			cmd.args["local_size"] = localSize
+		}
+	}
+	return cmd
+}
+
+// kernelCall adds the host call of a kernel enqueue to the timeline, if any.
+func (queue *Queue) kernelCall(fn string, kernelName string, start time.Time, globalSize, localSize []uint64) {
+	if queue.runner.timeline == nil {
+		return
+	}
+	var args = queue.kernelCommand(kernelName, start, globalSize, localSize).args
+	args["kernel"] = kernelName
+	args["queue"] = queue.Index
+	queue.runner.timeline.call(fn, start, args)
+}
+
+// hostCall returns the name of the API call that enqueued a transfer.
+func hostCall(name string, withEvent bool) string {
+	if withEvent {
+		return "Enqueue" + name
+	}
+	return name
+}
+
+// chromeEvent is an event of the Chrome Trace Event format.
+type chromeEvent struct {
+	Name string         `json:"name"`
+	Cat  string         `json:"cat,omitempty"`
+	Ph   string         `json:"ph"`
+	Ts   float64        `json:"ts"`
+	Dur  float64        `json:"dur,omitempty"`
+	Pid  int            `json:"pid"`
+	Tid  int            `json:"tid"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+func microseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+// WriteTimeline writes the host calls and device commands recorded since the runner was created,
+// or since ResetTimeline, in the Chrome Trace Event JSON format, which chrome://tracing and Perfetto load.
+//
+// Host calls are on the "host" process, on as many tracks as calls overlapped. Each device is a process
+// with a track per command queue, showing the execution of kernels and transfers that have completed.
+// Device timestamps are aligned with the host clock at the first command of each device,
+// so gaps between commands are exact while the offset to the host calls is approximate.
+// The runner must have been created with WithTimeline.
+func (runner *OpenCLRunner) WriteTimeline(w io.Writer) error {
+	if runner.timeline == nil {
+		return fmt.Errorf("WriteTimeline Err: the runner was not created with WithTimeline")
+	}
+	runner.collectPendingEvents()
+
+	var t = runner.timeline
+	t.mu.Lock()
+	var calls = append([]timelineCall(nil), t.calls...)
+	var commands = append([]timelineCommand(nil), t.commands...)
+	t.mu.Unlock()
+
+	var events = []chromeEvent{{Name: "process_name", Ph: "M", Pid: 0, Args: map[string]any{"name": "host"}}}
+
+	// host calls go to the first track where they do not overlap another call, or are nested in one,
+	// e.g. the Wait of a RunKernel
+	sort.SliceStable(calls, func(i, j int) bool {
+		if calls[i].start != calls[j].start {
+			return calls[i].start < calls[j].start
+		}
+		return calls[i].end > calls[j].end
+	})
+	var tracks [][]time.Duration // the ends of the open calls of each track
+	for _, call := range calls {
+		var tid = len(tracks)
+		for i, open := range tracks {
+			for len(open) > 0 && open[len(open)-1] <= call.start {
+				open = open[:len(open)-1]
+			}
+			tracks[i] = open
+			if len(open) == 0 || call.end <= open[len(open)-1] {
+				tid = i
+				break
+			}
+		}
+		if tid == len(tracks) {
+			var name = "host API"
+			if tid > 0 {
+				name = fmt.Sprintf("host API %d", tid+1)
+			}
+			events = append(events, chromeEvent{Name: "thread_name", Ph: "M", Pid: 0, Tid: tid, Args: map[string]any{"name": name}})
+			tracks = append(tracks, nil)
+		}
+		tracks[tid] = append(tracks[tid], call.end)
+		events = append(events, chromeEvent{Name: call.name, Cat: "host", Ph: "X", Ts: microseconds(call.start),
+			Dur: microseconds(call.end - call.start), Pid: 0, Tid: tid, Args: call.args})
+	}
+
+	var devices = make(map[*OpenCLDevice]int)
+	for i, device := range runner.Devices {
+		devices[device] = i + 1
+		events = append(events, chromeEvent{Name: "process_name", Ph: "M", Pid: i + 1,
+			Args: map[string]any{"name": fmt.Sprintf("device %d: %s", i, device.Name)}})
+	}
+	for _, queue := range runner.Queues {
+		events = append(events, chromeEvent{Name: "thread_name", Ph: "M", Pid: devices[queue.Device], Tid: queue.Index,
+			Args: map[string]any{"name": fmt.Sprintf("queue %d", queue.Index)}})
+	}
+
+	sort.SliceStable(commands, func(i, j int) bool { return commands[i].profile.Queued < commands[j].profile.Queued })
+	var offsets = make(map[*OpenCLDevice]time.Duration)
+	for _, command := range commands {
+		var device = command.queue.Device
+		var offset, ok = offsets[device]
+		if !ok {
+			offset = command.enqueued - command.profile.Queued
+			offsets[device] = offset
+		}
+		var args = map[string]any{"queued_us": microseconds(command.profile.Started - command.profile.Queued)}
+		for key, value := range command.args {
+			args[key] = value
+		}
+		events = append(events, chromeEvent{Name: command.name, Cat: "device", Ph: "X",
+			Ts: microseconds(command.profile.Started + offset), Dur: microseconds(command.profile.Duration()),
+			Pid: devices[device], Tid: command.queue.Index, Args: args})
+	}
+
+	var encoder = json.NewEncoder(w)
+	if err := encoder.Encode(map[string]any{"traceEvents": events, "displayTimeUnit": "ns"}); err != nil {
+		return fmt.Errorf("WriteTimeline Err: %w", err)
+	}
+	return nil
+}
+
+// ResetTimeline discards the recorded host calls and device commands.
+func (runner *OpenCLRunner) ResetTimeline() {
+	if runner.timeline == nil {
+		return
+	}
+	runner.collectPendingEvents()
+	runner.timeline.mu.Lock()
+	defer runner.timeline.mu.Unlock()
+	runner.timeline.calls = nil
+	runner.timeline.commands = nil
+}
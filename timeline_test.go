@@ -0,0 +1,98 @@
+package opencl
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// readTimeline writes the timeline of the runner and returns its events.
+func readTimeline(t *testing.T, runner *OpenCLRunner) []chromeEvent {
+	var buf bytes.Buffer
+	if err := runner.WriteTimeline(&buf); err != nil {
+		t.Fatal("WriteTimeline err:", err)
+	}
+	var timeline struct {
+		TraceEvents []chromeEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &timeline); err != nil {
+		t.Fatalf("timeline is not JSON: %v\n%s", err, buf.String())
+	}
+	return timeline.TraceEvents
+}
+
+func TestTimeline(t *testing.T) {
+	var runner = newFakeTestRunner(t, WithTimeline())
+	input := []int32{1, 2, 3, 4, 5, 6, 7, 8}
+	inputBuf, err := CreateBuffer(runner, READ_ONLY|COPY_HOST_PTR, input)
+	if err != nil {
+		t.Fatal("CreateBuffer err:", err)
+	}
+	outputBuf, err := runner.CreateEmptyBuffer(WRITE_ONLY, 4*len(input))
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	var factor int32 = 1
+	err = runner.RunKernel("square", 1, nil, []uint64{8}, []uint64{4}, []KernelParam{
+		BufferParam(inputBuf), BufferParam(outputBuf), Param(&factor),
+	}, true)
+	if err != nil {
+		t.Fatal("RunKernel err:", err)
+	}
+	event, err := EnqueueReadBuffer(runner, 0, outputBuf, input, false, nil)
+	if err != nil {
+		t.Fatal("EnqueueReadBuffer err:", err)
+	}
+	if err = event.Wait(); err != nil {
+		t.Fatal("Wait err:", err)
+	}
+	event.Release()
+
+	var names = make(map[[2]int]string)
+	var host = make(map[string]chromeEvent)
+	var device = make(map[string]chromeEvent)
+	for _, e := range readTimeline(t, runner) {
+		switch {
+		case e.Ph == "M" && e.Name == "thread_name":
+			names[[2]int{e.Pid, e.Tid}] = e.Args["name"].(string)
+		case e.Ph == "X" && e.Pid == 0:
+			host[e.Name] = e
+		case e.Ph == "X":
+			device[e.Name] = e
+		}
+	}
+
+	if names[[2]int{1, 0}] != "queue 0" || names[[2]int{0, 0}] != "host API" {
+		t.Errorf("track names %v", names)
+	}
+	for _, name := range []string{"BuildProgram", "CreateBuffer", "RunKernel", "Wait", "EnqueueReadBuffer"} {
+		if _, ok := host[name]; !ok {
+			t.Errorf("no host call %s in %v", name, host)
+		}
+	}
+	if run, wait := host["RunKernel"], host["Wait"]; run.Args["kernel"] != "square" || wait.Tid != run.Tid {
+		t.Errorf("RunKernel %+v and its Wait %+v are not on the same track", run, wait)
+	}
+	if kernel, ok := device["square"]; !ok || kernel.Pid != 1 || kernel.Tid != 0 || kernel.Cat != "device" {
+		t.Errorf("kernel event %+v", kernel)
+	} else if global, local := kernel.Args["global_size"], kernel.Args["local_size"]; len(global.([]any)) != 1 || local.([]any)[0] != 4.0 {
+		t.Errorf("kernel sizes %v %v", global, local)
+	}
+	if read := device["ReadBuffer"]; read.Args["bytes"] != 32.0 {
+		t.Errorf("read event %+v, want 32 bytes", read)
+	}
+
+	runner.ResetTimeline()
+	for _, e := range readTimeline(t, runner) {
+		if e.Ph == "X" {
+			t.Errorf("event %+v after ResetTimeline", e)
+		}
+	}
+}
+
+func TestTimelineDisabled(t *testing.T) {
+	var runner = newFakeTestRunner(t)
+	if err := runner.WriteTimeline(&bytes.Buffer{}); err == nil {
+		t.Error("WriteTimeline without WithTimeline succeeded")
+	}
+}
@@ -0,0 +1,485 @@
+package opencl
+
+// #include "cl.h"
+import "C"
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// TraceContent selects how much of the buffer contents a trace records.
+type TraceContent int
+
+const (
+	// TraceSizes records offsets and sizes only.
+	TraceSizes TraceContent = iota
+	// TraceHashes also records an FNV-1a hash of the data written to and read from buffers.
+	TraceHashes
+	// TraceSnapshots also records the data itself, which is needed to replay a trace.
+	TraceSnapshots
+)
+
+// TraceOp is the runner call a TraceRecord describes.
+type TraceOp uint8
+
+const (
+	TraceCreateBuffer TraceOp = iota + 1
+	TraceBuildProgram
+	TraceAddProgram
+	TraceRemoveProgram
+	TraceSetArgs
+	TraceEnqueueKernel
+	TraceWriteBuffer
+	TraceReadBuffer
+	TraceReadResult // the data of a non-blocking TraceReadBuffer, once it has completed
+	TraceMapBuffer
+	TraceUnmapBuffer
+)
+
+var traceOpNames = map[TraceOp]string{
+	TraceCreateBuffer:  "CreateBuffer",
+	TraceBuildProgram:  "BuildProgram",
+	TraceAddProgram:    "AddProgram",
+	TraceRemoveProgram: "RemoveProgram",
+	TraceSetArgs:       "SetArgs",
+	TraceEnqueueKernel: "EnqueueKernel",
+	TraceWriteBuffer:   "WriteBuffer",
+	TraceReadBuffer:    "ReadBuffer",
+	TraceReadResult:    "ReadResult",
+	TraceMapBuffer:     "MapBuffer",
+	TraceUnmapBuffer:   "UnmapBuffer",
+}
+
+func (op TraceOp) String() string {
+	if name, ok := traceOpNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("TraceOp(%d)", op)
+}
+
+// traceVersion is the version of the trace format written by WithTrace.
+const traceVersion = 2
+
+// TraceHeader describes the runner a trace was recorded on.
+type TraceHeader struct {
+	Version int
+	Devices []string
+	Queues  int // the number of command queues per device
+	Content TraceContent
+	Start   time.Time
+}
+
+// TraceRecord is one call made on a traced runner. Only the fields relevant to Op are set.
+// Buffers and programs are identified by numbers assigned in the order they are created.
+type TraceRecord struct {
+	Op       TraceOp
+	Time     time.Duration // when the call was made, since TraceHeader.Start
+	Duration time.Duration // how long the call took on the host
+	Err      string        // the error the call returned, if any
+
+	Queue   int    // the index of the queue in OpenCLRunner.Queues
+	Buffer  uint64 // the buffer created, written, read or mapped
+	Program uint64 // the program built, added or removed, or the program of the kernel of SetArgs
+	Ref     int    // the index of the TraceReadBuffer of a TraceReadResult, or of the TraceMapBuffer of a TraceUnmapBuffer
+
+	// buffer calls
+	Flags    uint64 // mem flags or map flags
+	Offset   int
+	Size     int
+	Blocking bool
+	Hash     uint64 // the hash of the data, with TraceHashes or TraceSnapshots
+	Data     []byte // the data, with TraceSnapshots
+
+	// program and kernel calls
+	Sources      []string
+	Kernels      []string
+	Options      string
+	Kernel       string
+	WorkDim      int
+	GlobalOffset []uint64
+	GlobalSize   []uint64
+	LocalSize    []uint64
+	Args         []TraceArg
+}
+
+// TraceArgKind is the kind of a kernel argument in a trace.
+type TraceArgKind uint8
+
+const (
+	TraceArgBuffer TraceArgKind = iota + 1
+	TraceArgLocal
+	TraceArgValue
+)
+
+// TraceArg is a kernel argument: a buffer, a local memory size or the bytes of a value, as Kind tells.
+type TraceArg struct {
+	Kind   TraceArgKind
+	Buffer uint64
+	Local  int
+	Value  []byte
+}
+
+// WithTrace records the calls made on the runner to w, for debugging and for Trace.Replay:
+// buffer creation, program builds, kernel arguments and enqueues, buffer reads, writes and mappings,
+// with their arguments, timestamps and, depending on content, the data transferred.
+// The trace is gzip-compressed; it is complete once the runner is freed, w is not closed.
+// Writes to the host memory of USE_HOST_PTR buffers are not recorded.
+func WithTrace(w io.Writer, content TraceContent) RunnerOption {
+	return func(config *runnerConfig) {
+		config.trace = w
+		config.traceContent = content
+	}
+}
+
+// tracer writes the trace of a runner.
+type tracer struct {
+	mu      sync.Mutex
+	content TraceContent
+	start   time.Time
+	zw      *gzip.Writer
+	enc     *gob.Encoder
+	count   int    // the number of records written
+	lastID  uint64 // the last buffer or program number
+	mapped  map[unsafe.Pointer]int
+	err     error
+}
+
+func newTracer(w io.Writer, content TraceContent, runner *OpenCLRunner, queueCount int) (*tracer, error) {
+	var t = &tracer{content: content, start: time.Now(), zw: gzip.NewWriter(w), mapped: make(map[unsafe.Pointer]int)}
+	t.enc = gob.NewEncoder(t.zw)
+	var header = TraceHeader{Version: traceVersion, Queues: queueCount, Content: content, Start: t.start}
+	for _, device := range runner.Devices {
+		header.Devices = append(header.Devices, device.Name)
+	}
+	if err := t.enc.Encode(&header); err != nil {
+		return nil, fmt.Errorf("WithTrace Err: %w", err)
+	}
+	return t, nil
+}
+
+// id returns the number of a new buffer or program.
+func (t *tracer) id() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastID++
+	return t.lastID
+}
+
+// record writes a record of a call that started at start and returns its index.
+// Once writing has failed, nothing more is written and the error is returned by close.
+func (t *tracer) record(start time.Time, record *TraceRecord, err error) int {
+	record.Time = start.Sub(t.start)
+	record.Duration = time.Since(start)
+	if err != nil {
+		record.Err = err.Error()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return -1
+	}
+	if t.err = t.enc.Encode(record); t.err != nil {
+		return -1
+	}
+	t.count++
+	return t.count - 1
+}
+
+// data sets the hash and data of a record as selected by the trace content.
+func (t *tracer) data(record *TraceRecord, data []byte) {
+	if t.content >= TraceHashes {
+		record.Hash = traceHash(data)
+	}
+	if t.content >= TraceSnapshots {
+		record.Data = append([]byte(nil), data...)
+	}
+}
+
+// close completes the trace.
+func (t *tracer) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.zw.Close(); err != nil && t.err == nil {
+		t.err = err
+	}
+	if t.err != nil {
+		return fmt.Errorf("WithTrace Err: %w", t.err)
+	}
+	return nil
+}
+
+func traceHash(data []byte) uint64 {
+	var h = fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// traceArgs returns the trace of kernel parameters.
+func traceArgs(args []KernelParam) []TraceArg {
+	var result = make([]TraceArg, len(args))
+	for i, arg := range args {
+		switch {
+		case arg.buffer != nil:
+			result[i] = TraceArg{Kind: TraceArgBuffer, Buffer: arg.buffer.id}
+		case arg.Pointer == nil:
+			result[i] = TraceArg{Kind: TraceArgLocal, Local: int(arg.Size)}
+		default:
+			result[i] = TraceArg{Kind: TraceArgValue, Value: append([]byte(nil), unsafe.Slice((*byte)(arg.Pointer), arg.Size)...)}
+		}
+	}
+	return result
+}
+
+// hostBytes returns size bytes of host memory, or nil.
+func hostBytes(ptr unsafe.Pointer, size int) []byte {
+	if ptr == nil {
+		return nil
+	}
+	return unsafe.Slice((*byte)(ptr), size)
+}
+
+// Trace is a trace recorded with WithTrace.
+type Trace struct {
+	Header  TraceHeader
+	Records []TraceRecord
+}
+
+// ReadTrace reads a trace recorded with WithTrace.
+// If the trace is truncated, e.g. because the process crashed before the runner was freed,
+// the records read so far are returned together with the error.
+func ReadTrace(r io.Reader) (*Trace, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("ReadTrace Err: %w", err)
+	}
+	var dec = gob.NewDecoder(zr)
+	var trace Trace
+	if err = dec.Decode(&trace.Header); err != nil {
+		return nil, fmt.Errorf("ReadTrace Err: %w", err)
+	}
+	if trace.Header.Version != traceVersion {
+		return nil, fmt.Errorf("ReadTrace Err: unsupported trace version %d", trace.Header.Version)
+	}
+	for {
+		var record TraceRecord
+		if err = dec.Decode(&record); err != nil {
+			if err == io.EOF {
+				return &trace, nil
+			}
+			return &trace, fmt.Errorf("ReadTrace Err: record %d: %w", len(trace.Records), err)
+		}
+		trace.Records = append(trace.Records, record)
+	}
+}
+
+// TraceDiff is a read-back whose replayed data differs from the recorded data.
+type TraceDiff struct {
+	Record     int // the index of the TraceReadBuffer, TraceReadResult or TraceMapBuffer record
+	Op         TraceOp
+	Buffer     uint64
+	Offset     int
+	Size       int
+	Mismatches int // the number of differing bytes, -1 if only the hash was recorded
+	First      int // the offset of the first differing byte in the read-back, -1 if only the hash was recorded
+}
+
+func (d TraceDiff) String() string {
+	var s = fmt.Sprintf("record %d: %s of buffer %d at offset %d, %d bytes: ", d.Record, d.Op, d.Buffer, d.Offset, d.Size)
+	if d.Mismatches < 0 {
+		return s + "hash differs"
+	}
+	return s + fmt.Sprintf("%d bytes differ, first at %d", d.Mismatches, d.First)
+}
+
+// errNoSnapshot is returned by Replay for a trace recorded without TraceSnapshots.
+var errNoSnapshot = errors.New("data was not recorded, record the trace with TraceSnapshots")
+
+// Replay executes the calls of the trace on runner and compares the data read back with the recorded data.
+// Commands run one at a time in the recorded order; calls that failed when recorded are skipped.
+// Queues are mapped to the queues of runner by index, modulo their number.
+// The buffers and programs created by the replay are released by the runner's Free.
+func (trace *Trace) Replay(runner *OpenCLRunner) ([]TraceDiff, error) {
+	var r = replayer{
+		trace:    trace,
+		runner:   runner,
+		buffers:  make(map[uint64]*Buffer),
+		programs: make(map[uint64]*Program),
+		reads:    make(map[int][]byte),
+		maps:     make(map[int][]byte),
+	}
+	for i := range trace.Records {
+		var record = &trace.Records[i]
+		if record.Err != "" {
+			continue
+		}
+		if err := r.replay(i, record); err != nil {
+			return r.diffs, fmt.Errorf("Replay Err: record %d (%s): %w", i, record.Op, err)
+		}
+	}
+	return r.diffs, nil
+}
+
+type replayer struct {
+	trace    *Trace
+	runner   *OpenCLRunner
+	buffers  map[uint64]*Buffer
+	programs map[uint64]*Program
+	reads    map[int][]byte // the data of non-blocking reads by record index
+	maps     map[int][]byte // the mapped memory by record index
+	diffs    []TraceDiff
+}
+
+func (r *replayer) replay(index int, record *TraceRecord) error {
+	var queue = r.runner.Queues[record.Queue%len(r.runner.Queues)]
+	switch record.Op {
+	case TraceCreateBuffer:
+		var buffer *Buffer
+		var err error
+		if record.Flags&uint64(USE_HOST_PTR|COPY_HOST_PTR) != 0 {
+			if record.Data == nil {
+				return errNoSnapshot
+			}
+			buffer, err = CreateBuffer(r.runner, C.cl_mem_flags(record.Flags), append([]byte(nil), record.Data...))
+		} else {
+			buffer, err = r.runner.CreateEmptyBuffer(C.cl_mem_flags(record.Flags), record.Size)
+		}
+		if err != nil {
+			return err
+		}
+		r.buffers[record.Buffer] = buffer
+	case TraceBuildProgram:
+		program, err := r.runner.BuildProgram(record.Sources, record.Kernels, record.Options)
+		if err != nil {
+			return err
+		}
+		r.programs[record.Program] = program
+	case TraceAddProgram, TraceRemoveProgram:
+		var program = r.programs[record.Program]
+		if program == nil {
+			return fmt.Errorf("program %d was not built", record.Program)
+		}
+		if record.Op == TraceAddProgram {
+			return r.runner.AddProgram(program)
+		}
+		return r.runner.RemoveProgram(program)
+	case TraceSetArgs:
+		var program = r.programs[record.Program]
+		if program == nil || program.Kernels[record.Kernel] == nil {
+			return fmt.Errorf("kernel %q of program %d was not built", record.Kernel, record.Program)
+		}
+		args, err := r.args(record.Args)
+		if err != nil {
+			return err
+		}
+		return program.Kernels[record.Kernel].SetArgs(args)
+	case TraceEnqueueKernel:
+		args, err := r.args(record.Args)
+		if err != nil {
+			return err
+		}
+		return queue.RunKernel(record.Kernel, record.WorkDim, record.GlobalOffset, record.GlobalSize, record.LocalSize, args, true)
+	case TraceWriteBuffer:
+		if record.Data == nil {
+			return errNoSnapshot
+		}
+		return WriteBuffer(queue, record.Offset, r.buffers[record.Buffer], record.Data, true)
+	case TraceReadBuffer:
+		var data = make([]byte, record.Size)
+		if err := ReadBuffer(queue, record.Offset, r.buffers[record.Buffer], data); err != nil {
+			return err
+		}
+		if record.Blocking {
+			r.compare(index, record, record, data)
+		} else {
+			r.reads[index] = data
+		}
+	case TraceReadResult:
+		if data, ok := r.reads[record.Ref]; ok {
+			r.compare(record.Ref, &r.trace.Records[record.Ref], record, data)
+			delete(r.reads, record.Ref)
+		}
+	case TraceMapBuffer:
+		data, err := MapBuffer[byte](queue, r.buffers[record.Buffer], C.cl_map_flags(record.Flags), record.Offset, record.Size)
+		if err != nil {
+			return err
+		}
+		r.compare(index, record, record, data)
+		r.maps[index] = data
+	case TraceUnmapBuffer:
+		var data, ok = r.maps[record.Ref]
+		if !ok {
+			return fmt.Errorf("record %d is not a replayed MapBuffer", record.Ref)
+		}
+		delete(r.maps, record.Ref)
+		if C.cl_map_flags(r.trace.Records[record.Ref].Flags)&MAP_WRITE != 0 {
+			if record.Data == nil {
+				return errNoSnapshot
+			}
+			copy(data, record.Data)
+		}
+		return UnmapBuffer(queue, r.buffers[record.Buffer], data)
+	default:
+		return fmt.Errorf("unknown operation")
+	}
+	return nil
+}
+
+// args returns the kernel parameters of traced arguments.
+func (r *replayer) args(args []TraceArg) ([]KernelParam, error) {
+	var params = make([]KernelParam, len(args))
+	for i, arg := range args {
+		switch arg.Kind {
+		case TraceArgBuffer:
+			var buffer, ok = r.buffers[arg.Buffer]
+			if !ok {
+				return nil, fmt.Errorf("argument %d: buffer %d was not created", i, arg.Buffer)
+			}
+			params[i] = BufferParam(buffer)
+		case TraceArgLocal:
+			params[i] = LocalParam(arg.Local)
+		case TraceArgValue:
+			// a zero-sized value still needs a pointer
+			var value = append(make([]byte, 0, max(len(arg.Value), 1)), arg.Value...)
+			params[i] = KernelParam{Size: uintptr(len(value)), Pointer: unsafe.Pointer(unsafe.SliceData(value))}
+		default:
+			return nil, fmt.Errorf("argument %d: unknown kind %d", i, arg.Kind)
+		}
+	}
+	return params, nil
+}
+
+// compare adds a diff if the replayed data of a read differs from the data recorded in result.
+func (r *replayer) compare(index int, read *TraceRecord, result *TraceRecord, data []byte) {
+	var diff = TraceDiff{Record: index, Op: read.Op, Buffer: read.Buffer, Offset: read.Offset, Size: read.Size, First: -1}
+	switch {
+	case result.Data != nil:
+		for i := range data {
+			if i >= len(result.Data) || data[i] != result.Data[i] {
+				if diff.Mismatches == 0 {
+					diff.First = i
+				}
+				diff.Mismatches++
+			}
+		}
+		if diff.Mismatches == 0 {
+			return
+		}
+	case r.trace.Header.Content >= TraceHashes:
+		if traceHash(data) == result.Hash {
+			return
+		}
+		diff.Mismatches = -1
+	default:
+		return
+	}
+	r.diffs = append(r.diffs, diff)
+}
@@ -0,0 +1,173 @@
+package opencl
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// recordTestTrace runs kernels of fakeTestSource on a traced runner and returns the trace.
+func recordTestTrace(t *testing.T, content TraceContent) *Trace {
+	var buf bytes.Buffer
+	var runner = newFakeTestBackendRunner(t, newFakeTestBackend(), WithTrace(&buf, content))
+
+	input := []int32{1, 2, 3, 4, 5, 6, 7, 8}
+	inputBuf, err := CreateBuffer(runner, READ_WRITE|COPY_HOST_PTR, input)
+	if err != nil {
+		t.Fatal("CreateBuffer err:", err)
+	}
+	outputBuf, err := runner.CreateEmptyBuffer(WRITE_ONLY, 4*len(input))
+	if err != nil {
+		t.Fatal("CreateEmptyBuffer err:", err)
+	}
+	var factor int32 = 2
+	var args = []KernelParam{BufferParam(inputBuf), BufferParam(outputBuf), Param(&factor)}
+	if err = runner.RunKernel("square", 1, nil, []uint64{8}, nil, args, true); err != nil {
+		t.Fatal("RunKernel err:", err)
+	}
+
+	result := make([]int32, len(input))
+	event, err := EnqueueReadBuffer(runner, 0, outputBuf, result, false, nil)
+	if err != nil {
+		t.Fatal("EnqueueReadBuffer err:", err)
+	}
+	if err = event.Wait(); err != nil {
+		t.Fatal("Wait err:", err)
+	}
+	event.Release()
+
+	mapped, err := MapBuffer[int32](runner, inputBuf, MAP_WRITE, 0, len(input))
+	if err != nil {
+		t.Fatal("MapBuffer err:", err)
+	}
+	mapped[0] = 10
+	if err = UnmapBuffer(runner, inputBuf, mapped); err != nil {
+		t.Fatal("UnmapBuffer err:", err)
+	}
+	if err = runner.SetKernelArgs("square", args); err != nil {
+		t.Fatal("SetKernelArgs err:", err)
+	}
+	if err = runner.RunKernel("square", 1, nil, []uint64{8}, nil, nil, true); err != nil {
+		t.Fatal("RunKernel err:", err)
+	}
+	if err = ReadBuffer(runner, 0, outputBuf, result); err != nil {
+		t.Fatal("ReadBuffer err:", err)
+	}
+	if err = runner.Free(); err != nil {
+		t.Fatal("Free err:", err)
+	}
+
+	trace, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal("ReadTrace err:", err)
+	}
+	return trace
+}
+
+// newReplayRunner returns a runner of the backend without programs, freed by the test cleanup.
+func newReplayRunner(t *testing.T, backend *FakeBackend) *OpenCLRunner {
+	runner, err := backend.InitRunner()
+	if err != nil {
+		t.Fatal("InitRunner err:", err)
+	}
+	t.Cleanup(func() { runner.Free() })
+	return runner
+}
+
+func TestTraceReplay(t *testing.T) {
+	var trace = recordTestTrace(t, TraceSnapshots)
+	if trace.Header.Content != TraceSnapshots || len(trace.Header.Devices) != 1 || trace.Header.Queues != 1 {
+		t.Errorf("header %+v", trace.Header)
+	}
+	var ops []TraceOp
+	for _, record := range trace.Records {
+		ops = append(ops, record.Op)
+	}
+	var want = []TraceOp{TraceBuildProgram, TraceAddProgram, TraceCreateBuffer, TraceCreateBuffer, TraceEnqueueKernel,
+		TraceReadBuffer, TraceReadResult, TraceMapBuffer, TraceUnmapBuffer, TraceSetArgs, TraceEnqueueKernel, TraceReadBuffer}
+	if !slices.Equal(ops, want) {
+		t.Fatalf("ops %v, want %v", ops, want)
+	}
+	if unmap := trace.Records[8]; unmap.Ref != 7 || unmap.Data[0] != 10 {
+		t.Errorf("unmap record %+v, want the data written to the mapping of record 7", unmap)
+	}
+	if kernel := trace.Records[4]; kernel.Kernel != "square" || len(kernel.Args) != 3 || kernel.Args[1].Buffer != trace.Records[3].Buffer {
+		t.Errorf("kernel record %+v", kernel)
+	}
+	var kinds []TraceArgKind
+	for _, arg := range trace.Records[4].Args {
+		kinds = append(kinds, arg.Kind)
+	}
+	if want := []TraceArgKind{TraceArgBuffer, TraceArgBuffer, TraceArgValue}; !slices.Equal(kinds, want) {
+		t.Errorf("argument kinds %v, want %v", kinds, want)
+	}
+
+	diffs, err := trace.Replay(newReplayRunner(t, newFakeTestBackend()))
+	if err != nil || len(diffs) != 0 {
+		t.Fatalf("Replay on the same kernels: diffs %v, err %v", diffs, err)
+	}
+
+	// replay with a kernel that computes something else
+	var backend = newFakeTestBackend()
+	backend.RegisterKernel("square", func(item WorkItem, args FakeArgs) {
+		var in, out = FakeSlice[int32](args[0]), FakeSlice[int32](args[1])
+		out[item.GlobalID[0]] = in[item.GlobalID[0]] * 3
+	})
+	diffs, err = trace.Replay(newReplayRunner(t, backend))
+	if err != nil {
+		t.Fatal("Replay err:", err)
+	}
+	var records []int
+	for _, diff := range diffs {
+		records = append(records, diff.Record)
+		if diff.Op != TraceReadBuffer || diff.Mismatches <= 0 || diff.First != 0 {
+			t.Errorf("diff %v", diff)
+		}
+	}
+	if !slices.Equal(records, []int{5, 11}) {
+		t.Errorf("diffs of records %v, want [5 11]", records)
+	}
+}
+
+func TestTraceReplayArgs(t *testing.T) {
+	var empty struct{}
+	var args = traceArgs([]KernelParam{Param(&empty), LocalParam(0)})
+	if args[0].Kind != TraceArgValue || args[1].Kind != TraceArgLocal {
+		t.Fatalf("traced arguments %+v, want a value and local memory", args)
+	}
+	var r = replayer{buffers: make(map[uint64]*Buffer)}
+	params, err := r.args(args)
+	if err != nil {
+		t.Fatal("args err:", err)
+	}
+	if params[0].Pointer == nil || params[0].Size != 0 || params[1].Pointer != nil {
+		t.Errorf("replayed arguments %+v, want a zero-sized value and local memory", params)
+	}
+	if _, err = r.args([]TraceArg{{Kind: TraceArgBuffer, Buffer: 3}}); err == nil {
+		t.Error("args of a buffer that was not created succeeded")
+	}
+
+	// a program that was not built fails the replay
+	for _, op := range []TraceOp{TraceAddProgram, TraceRemoveProgram} {
+		var trace = &Trace{Records: []TraceRecord{{Op: op, Program: 1}}}
+		if _, err = trace.Replay(newReplayRunner(t, newFakeTestBackend())); err == nil {
+			t.Errorf("Replay of %s of a program that was not built succeeded", op)
+		}
+	}
+}
+
+func TestTraceHashes(t *testing.T) {
+	var trace = recordTestTrace(t, TraceHashes)
+	for _, record := range trace.Records {
+		if record.Data != nil {
+			t.Fatalf("%s recorded data with TraceHashes", record.Op)
+		}
+		if record.Op == TraceReadBuffer && record.Blocking && record.Hash == 0 {
+			t.Errorf("%s has no hash", record.Op)
+		}
+	}
+	if _, err := trace.Replay(newReplayRunner(t, newFakeTestBackend())); !errors.Is(err, errNoSnapshot) {
+		t.Errorf("Replay without snapshots = %v, want %v", err, errNoSnapshot)
+	}
+}
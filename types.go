@@ -3,30 +3,267 @@ package opencl
 // #include "cl.h"
 import "C"
 
+import (
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// DeviceType is a bitmask of OpenCL device types.
+type DeviceType uint64
+
+const (
+	DeviceTypeDefault     DeviceType = C.CL_DEVICE_TYPE_DEFAULT
+	DeviceTypeCPU         DeviceType = C.CL_DEVICE_TYPE_CPU
+	DeviceTypeGPU         DeviceType = C.CL_DEVICE_TYPE_GPU
+	DeviceTypeAccelerator DeviceType = C.CL_DEVICE_TYPE_ACCELERATOR
+	DeviceTypeCustom      DeviceType = C.CL_DEVICE_TYPE_CUSTOM
+)
+
+var deviceTypeNames = []struct {
+	flag DeviceType
+	name string
+}{
+	{DeviceTypeDefault, "Default"},
+	{DeviceTypeCPU, "CPU"},
+	{DeviceTypeGPU, "GPU"},
+	{DeviceTypeAccelerator, "Accelerator"},
+	{DeviceTypeCustom, "Custom"},
+}
+
+func (t DeviceType) String() string {
+	if t == 0 {
+		return "None"
+	}
+	var names []string
+	for _, flag := range deviceTypeNames {
+		if t&flag.flag != 0 {
+			names = append(names, flag.name)
+			t &^= flag.flag
+		}
+	}
+	if t != 0 {
+		names = append(names, "0x"+strconv.FormatUint(uint64(t), 16))
+	}
+	return strings.Join(names, "|")
+}
+
+// PlatformID is an opaque handle of an OpenCL platform.
+type PlatformID struct {
+	id C.cl_platform_id
+}
+
+// Handle returns the cl_platform_id, e.g. to pass it to other OpenCL bindings.
+func (platform_id PlatformID) Handle() unsafe.Pointer {
+	return unsafe.Pointer(platform_id.id)
+}
+
+// DeviceID is an opaque handle of an OpenCL device.
+type DeviceID struct {
+	id C.cl_device_id
+}
+
+// Handle returns the cl_device_id, e.g. to pass it to other OpenCL bindings.
+func (device_id DeviceID) Handle() unsafe.Pointer {
+	return unsafe.Pointer(device_id.id)
+}
+
 type OpenCLDevice struct {
-	Device_id   C.cl_device_id
-	Platform_id C.cl_platform_id
+	Device_id   DeviceID
+	Platform_id PlatformID
 
-	Device_type    C.cl_device_type
+	Device_type    DeviceType
 	Name           string
 	Profile        string
 	Version        string
 	Vendor         string
 	Driver_version string
 
-	Max_clock_frequency C.cl_uint
-	Max_mem_alloc_size  C.cl_ulong
-	Global_mem_size     C.cl_ulong
-	Max_compute_units   C.cl_uint
-	Max_work_group_size C.size_t
+	Max_clock_frequency uint32 // in MHz
+	Max_mem_alloc_size  uint64
+	Global_mem_size     uint64
+	Max_compute_units   uint32
+	Max_work_group_size int
+
+	Max_work_item_dimensions uint32
+	Max_work_item_sizes      []int
+
+	Local_mem_size            uint64
+	Local_mem_type            LocalMemType
+	Max_constant_buffer_size  uint64
+	Max_constant_args         uint32
+	Global_mem_cache_type     CacheType
+	Global_mem_cache_size     uint64
+	Global_mem_cacheline_size uint32
+	Mem_base_addr_align       uint32 // in bits
+	Max_parameter_size        int
+
+	Image_support         bool
+	Max_read_image_args   uint32
+	Max_write_image_args  uint32
+	Max_samplers          uint32
+	Image2d_max_width     int
+	Image2d_max_height    int
+	Image3d_max_width     int
+	Image3d_max_height    int
+	Image3d_max_depth     int
+	Image_max_buffer_size int
+	Image_max_array_size  int
+
+	Preferred_vector_width VectorWidths
+	Native_vector_width    VectorWidths
+
+	Half_fp_config   FPConfig
+	Single_fp_config FPConfig
+	Double_fp_config FPConfig
+
+	Address_bits               uint32
+	Endian_little              bool
+	Host_unified_memory        bool
+	Error_correction_support   bool
+	Available                  bool
+	Compiler_available         bool
+	Profiling_timer_resolution uint64 // in nanoseconds
+
+	OpenCL_c_version string
+	Extensions       []string
+	Built_in_kernels []string
+
+	Parsed_version    Version       // parsed from Version
+	Parsed_c_version  Version       // parsed from OpenCL_c_version
+	Extension_set     ExtensionSet  // Extensions as a set
+	OpenCL_c_features []NameVersion // optional OpenCL C features of OpenCL 3.0 devices
 
-	Max_work_item_dimensions C.cl_uint
-	Max_work_item_sizes      []C.size_t
+	Partition_max_sub_devices uint32
+	Partition_properties      []PartitionProperty
+
+	// Info_errors records the queries that failed by field name; those fields keep their zero value.
+	Info_errors map[string]error
+}
+
+// VectorWidths holds the preferred or native vector width for each built-in scalar type.
+// A width of 0 means that the type is not supported.
+type VectorWidths struct {
+	Char   uint32
+	Short  uint32
+	Int    uint32
+	Long   uint32
+	Float  uint32
+	Double uint32
+	Half   uint32
+}
+
+// LocalMemType is the type of local memory of a device.
+type LocalMemType uint32
+
+const (
+	LocalMemNone   LocalMemType = C.CL_NONE
+	LocalMemLocal  LocalMemType = C.CL_LOCAL  // dedicated local memory
+	LocalMemGlobal LocalMemType = C.CL_GLOBAL // local memory emulated in global memory
+)
+
+func (t LocalMemType) String() string {
+	switch t {
+	case LocalMemNone:
+		return "None"
+	case LocalMemLocal:
+		return "Local"
+	case LocalMemGlobal:
+		return "Global"
+	}
+	return "LocalMemType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// CacheType is the type of the global memory cache of a device.
+type CacheType uint32
+
+const (
+	CacheNone      CacheType = C.CL_NONE
+	CacheReadOnly  CacheType = C.CL_READ_ONLY_CACHE
+	CacheReadWrite CacheType = C.CL_READ_WRITE_CACHE
+)
+
+func (t CacheType) String() string {
+	switch t {
+	case CacheNone:
+		return "None"
+	case CacheReadOnly:
+		return "Read-Only"
+	case CacheReadWrite:
+		return "Read/Write"
+	}
+	return "CacheType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// FPConfig is a bitmask of the floating-point capabilities of a device.
+type FPConfig uint64
+
+const (
+	FPDenorm                     FPConfig = C.CL_FP_DENORM
+	FPInfNaN                     FPConfig = C.CL_FP_INF_NAN
+	FPRoundToNearest             FPConfig = C.CL_FP_ROUND_TO_NEAREST
+	FPRoundToZero                FPConfig = C.CL_FP_ROUND_TO_ZERO
+	FPRoundToInf                 FPConfig = C.CL_FP_ROUND_TO_INF
+	FPFMA                        FPConfig = C.CL_FP_FMA
+	FPSoftFloat                  FPConfig = C.CL_FP_SOFT_FLOAT
+	FPCorrectlyRoundedDivideSqrt FPConfig = C.CL_FP_CORRECTLY_ROUNDED_DIVIDE_SQRT
+)
+
+var fpConfigNames = []struct {
+	flag FPConfig
+	name string
+}{
+	{FPDenorm, "Denorm"},
+	{FPInfNaN, "INF/NaN"},
+	{FPRoundToNearest, "RoundToNearest"},
+	{FPRoundToZero, "RoundToZero"},
+	{FPRoundToInf, "RoundToInf"},
+	{FPFMA, "FMA"},
+	{FPSoftFloat, "SoftFloat"},
+	{FPCorrectlyRoundedDivideSqrt, "CorrectlyRoundedDivideSqrt"},
+}
+
+func (config FPConfig) String() string {
+	if config == 0 {
+		return "None"
+	}
+	var names []string
+	for _, flag := range fpConfigNames {
+		if config&flag.flag != 0 {
+			names = append(names, flag.name)
+			config &^= flag.flag
+		}
+	}
+	if config != 0 {
+		names = append(names, "0x"+strconv.FormatUint(uint64(config), 16))
+	}
+	return strings.Join(names, "|")
+}
+
+// PartitionProperty is a way a device can be partitioned into sub-devices.
+type PartitionProperty int64
+
+const (
+	PartitionEqually          PartitionProperty = C.CL_DEVICE_PARTITION_EQUALLY
+	PartitionByCounts         PartitionProperty = C.CL_DEVICE_PARTITION_BY_COUNTS
+	PartitionByAffinityDomain PartitionProperty = C.CL_DEVICE_PARTITION_BY_AFFINITY_DOMAIN
+)
+
+func (p PartitionProperty) String() string {
+	switch p {
+	case PartitionEqually:
+		return "Equally"
+	case PartitionByCounts:
+		return "ByCounts"
+	case PartitionByAffinityDomain:
+		return "ByAffinityDomain"
+	}
+	return "PartitionProperty(0x" + strconv.FormatInt(int64(p), 16) + ")"
 }
 
 type OpenCLPlatform struct {
-	Platform_id  C.cl_platform_id
-	Device_count C.cl_uint
+	Platform_id  PlatformID
+	Device_count uint32 // len(Devices), without the devices that were skipped
 	Name         string
 	Profile      string
 	Version      string
@@ -35,6 +272,9 @@ type OpenCLPlatform struct {
 }
 
 type OpenCLInfo struct {
-	Platform_count C.cl_uint
+	Platform_count uint32 // len(Platforms), without the platforms that were skipped
 	Platforms      []*OpenCLPlatform
+
+	// Diagnostics lists the platforms and devices that could not be queried completely.
+	Diagnostics []InfoDiagnostic
 }
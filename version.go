@@ -0,0 +1,48 @@
+package opencl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is an OpenCL version as reported in version strings like "OpenCL 1.2 CUDA 12.2.138".
+type Version struct {
+	Major  int
+	Minor  int
+	Suffix string // the vendor-specific information after the version number
+}
+
+// ParseVersion parses a platform, device or OpenCL C version string:
+// "OpenCL <major>.<minor> <vendor-specific>" or "OpenCL C <major>.<minor> <vendor-specific>".
+func ParseVersion(s string) (Version, error) {
+	var rest = strings.TrimSpace(s)
+	if !strings.HasPrefix(rest, "OpenCL ") {
+		return Version{}, fmt.Errorf("invalid OpenCL version %q", s)
+	}
+	rest = strings.TrimPrefix(strings.TrimPrefix(rest, "OpenCL "), "C ")
+
+	var number, suffix, _ = strings.Cut(rest, " ")
+	var majorStr, minorStr, found = strings.Cut(number, ".")
+	if !found {
+		return Version{}, fmt.Errorf("invalid OpenCL version %q", s)
+	}
+	major, err := strconv.Atoi(majorStr)
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid OpenCL version %q", s)
+	}
+	minor, err := strconv.Atoi(minorStr)
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid OpenCL version %q", s)
+	}
+	return Version{Major: major, Minor: minor, Suffix: strings.TrimSpace(suffix)}, nil
+}
+
+// AtLeast reports whether the version is major.minor or newer.
+func (v Version) AtLeast(major, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+func (v Version) String() string {
+	return strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor)
+}